@@ -25,6 +25,17 @@ func BenchmarkCountMultipleWithRate(b *testing.B) {
 	}
 }
 
+func BenchmarkCountMultipleWithTags(b *testing.B) {
+	c, _ := NewTestClient("default")
+	c.Tags = []string{"env:prod", "service:gopher"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.CountMultiple("metric", 10)
+	}
+}
+
 func BenchmarkMeasure(b *testing.B) {
 	c, _ := NewTestClient("default")
 
@@ -44,3 +55,33 @@ func BenchmarkMeasureWithRate(b *testing.B) {
 		c.Measure("metric", 123*time.Millisecond, 0.999999)
 	}
 }
+
+func BenchmarkGauge(b *testing.B) {
+	c, _ := NewTestClient("default")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Gauge("metric", 123)
+	}
+}
+
+func BenchmarkGaugeInt64(b *testing.B) {
+	c, _ := NewTestClient("default")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GaugeInt64("metric", 123)
+	}
+}
+
+func BenchmarkGaugeFloat64(b *testing.B) {
+	c, _ := NewTestClient("default")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GaugeFloat64("metric", 123.456)
+	}
+}
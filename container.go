@@ -0,0 +1,49 @@
+package statsd
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// containerIDPattern matches the container id at the end of a cgroup path,
+// a docker/containerd id, an ECS task id or a cri-o/podman uuid.
+var containerIDPattern = regexp.MustCompile(`(?:^|[-_/])([0-9a-f]{64}|[0-9a-f]{32}-[0-9]+|[0-9a-f]{8}(?:-[0-9a-f]{4}){3}-[0-9a-f]{12})(?:\.scope)?$`)
+
+// WithContainerID sets the container id sent with every stat, including from
+// Substater clients, so a shared DogStatsD agent can tell where they came from.
+// Only the DogStatsDEncoder sends it.
+func WithContainerID(id string) Option {
+	return func(client *RemoteClient) {
+		client.containerID = id
+	}
+}
+
+// WithOriginDetection is the same as WithContainerID using the id of the container
+// the process is running in, found in /proc/self/cgroup. It does nothing if the
+// id can not be found, such as when not running in a container or not on Linux.
+func WithOriginDetection() Option {
+	return func(client *RemoteClient) {
+		client.containerID = discoverContainerID()
+	}
+}
+
+// parseContainerID returns the container id from the contents of a
+// /proc/<pid>/cgroup file, or an empty string if there isn't one.
+// Each line is hierarchy-id:controllers:path with the id at the end of the path.
+func parseContainerID(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if m := containerIDPattern.FindStringSubmatch(parts[2]); m != nil {
+			return m[1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,16 @@
+package statsd
+
+import (
+	"os"
+)
+
+// discoverContainerID returns the id of the container the process is running in.
+func discoverContainerID() string {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	return parseContainerID(f)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package statsd
+
+// discoverContainerID returns an empty string as
+// container ids can only be discovered on Linux.
+func discoverContainerID() string {
+	return ""
+}
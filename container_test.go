@@ -0,0 +1,101 @@
+package statsd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContainerID(t *testing.T) {
+	cases := []struct {
+		name     string
+		cgroup   string
+		expected string
+	}{
+		{
+			"docker",
+			"12:memory:/docker/3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860\n" +
+				"11:cpu,cpuacct:/docker/3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860\n",
+			"3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860",
+		},
+		{
+			"kubernetes",
+			"1:name=systemd:/kubepods/besteffort/pod3d274242-8ee0-11e9-a8a6-1e68d864ef1a/3e74d3fd9db4c9dd921ae05c2502fb984d0cde1b36e581b13f79c639da4518a1\n",
+			"3e74d3fd9db4c9dd921ae05c2502fb984d0cde1b36e581b13f79c639da4518a1",
+		},
+		{
+			"systemd scope",
+			"0::/system.slice/docker-34dc0b5e626f2c5c4c5170e34b10e7654ce36f0fcd532739f4445baabea03376.scope\n",
+			"34dc0b5e626f2c5c4c5170e34b10e7654ce36f0fcd532739f4445baabea03376",
+		},
+		{
+			"ecs",
+			"9:perf_event:/ecs/haissam-ecs-classic/5a0d5ceddf6c44c1928d367a815d890f/38fac3e99302b3622be089dd41e7ccf38aff368a86cc339972075136ee2710ce\n",
+			"38fac3e99302b3622be089dd41e7ccf38aff368a86cc339972075136ee2710ce",
+		},
+		{
+			"fargate",
+			"11:hugetlb:/ecs/55091c13-b8cf-4801-b527-f4601742204d/432624d2150b349fe35ba397284dea788c2bf66b885d14dfc1569b01890ca7da\n",
+			"432624d2150b349fe35ba397284dea788c2bf66b885d14dfc1569b01890ca7da",
+		},
+		{
+			"fargate task",
+			"1:name=systemd:/ecs/34dc0b5e626f2c5c4c5170e34b10e765-1234567890\n",
+			"34dc0b5e626f2c5c4c5170e34b10e765-1234567890",
+		},
+		{
+			"crio",
+			"0::/kubepods.slice/crio-2227daf62df6694645fee5df53c1f91271546a9560e8600a525690ae252b7f63.scope\n",
+			"2227daf62df6694645fee5df53c1f91271546a9560e8600a525690ae252b7f63",
+		},
+		{"not in a container", "12:memory:/user.slice\n0::/\n", ""},
+		{"empty", "", ""},
+		{"malformed", "not a cgroup file\n", ""},
+	}
+
+	for _, tc := range cases {
+		if id := parseContainerID(strings.NewReader(tc.cgroup)); id != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, id)
+		}
+	}
+}
+
+func TestClientContainerID(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+	WithContainerID("abc123")(c)
+
+	err := c.WithTags("env:prod").Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "prefix.count:1|c|#env:prod|c:abc123"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// substaters send it too
+	buf.Reset()
+	err = c.Substater("sub").Measure("timing", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "prefix.sub.timing:1|ms|c:abc123"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// ignored by the other dialects
+	buf.Reset()
+	c.encoder = EtsyEncoder{}
+	err = c.Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "prefix.count:1|c"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
@@ -0,0 +1,65 @@
+package statsd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithWriteTimeout sets the deadline for each write to the server, so a blocked
+// socket, such as a stalled TCP server, fails the write rather than blocking every
+// stat behind it. Over unixgram the default is 100ms, otherwise there is none.
+// It does not apply to custom transports, nor to connecting, as dialing and
+// TLS handshakes are limited by WithDialTimeout and do not hold up writes.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(client *RemoteClient) {
+		client.writeTimeout = timeout
+	}
+}
+
+// writeLock is a mutex that can stop waiting when a context ends.
+// The zero value is unlocked.
+type writeLock struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (l *writeLock) init() {
+	l.once.Do(func() {
+		l.ch = make(chan struct{}, 1)
+	})
+}
+
+// Lock waits until the lock is available.
+func (l *writeLock) Lock() {
+	l.init()
+	l.ch <- struct{}{}
+}
+
+// LockContext waits until the lock is available or the context ends,
+// returning the context's error if it did not get the lock.
+func (l *writeLock) LockContext(ctx context.Context) error {
+	l.init()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unlock releases the lock.
+func (l *writeLock) Unlock() {
+	<-l.ch
+}
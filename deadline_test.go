@@ -0,0 +1,156 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stalledServer returns the address of a TCP server that accepts
+// connections but never reads from them.
+func stalledServer(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestWriteLock(t *testing.T) {
+	var l writeLock
+	l.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := l.LockContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	l.Unlock()
+	if err := l.LockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock()
+
+	// an ended context does not lock
+	if err := l.LockContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	l.Lock()
+}
+
+func TestWithWriteTimeout(t *testing.T) {
+	c, err := NewWithOptions("tcp://"+stalledServer(t), WithWriteTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the stalled server's buffers fill up and the writes time out
+	value := strings.Repeat("v", 64*1024)
+	for i := 0; i < 10000; i++ {
+		err = c.Set("set", value)
+		if err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestWriteContext(t *testing.T) {
+	c, err := NewWithOptions("tcp://" + stalledServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	message := make([]byte, 64*1024)
+	for i := 0; i < 10000; i++ {
+		err = c.writeContext(ctx, message)
+		if err != nil {
+			break
+		}
+	}
+
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	c, err := NewWithOptions("tcp://"+stalledServer(t), WithPacking(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Measure("timing", time.Millisecond)
+
+	// a write is blocked
+	c.writeMutex.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = c.FlushContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	err = c.CloseContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if s := c.State(); s != StateClosed {
+		t.Fatalf("expected closed, got %v", s)
+	}
+
+	// the blocked write finishes
+	c.writeMutex.Unlock()
+
+	err = c.Count("count")
+	if err != ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	}
+
+	c.writeMutex.Lock()
+	closed := c.transport == nil
+	c.writeMutex.Unlock()
+
+	if !closed {
+		t.Fatal("should have closed the transport")
+	}
+}
@@ -0,0 +1,226 @@
+package statsd
+
+import (
+	"strconv"
+	"time"
+)
+
+// Metric is a single stat as it is passed to an Encoder.
+type Metric struct {
+	// Prefix is the client's prefix, without the trailing dot, and may be empty.
+	Prefix []byte
+	Name   string
+
+	// Value is the formatted value, such as 5, +3 or 1.5.
+	Value []byte
+
+	// Type is the StatsD type, one of c, ms, us, g, s, h or d.
+	Type string
+
+	// Rate is the sample rate, it is only sent when less than 1.
+	Rate float32
+
+	// Tags are in the DogStatsD format, "key:value" or just "key".
+	Tags []string
+
+	// Timestamp is when the value was recorded, the zero value means now.
+	Timestamp time.Time
+
+	// ContainerID identifies the container the stat came from, and may be empty.
+	ContainerID string
+}
+
+// Encoder formats a Metric into the wire format of a StatsD dialect.
+// Set it on a client using the WithEncoder option.
+type Encoder interface {
+	// Encode appends the encoded metric to dst and returns the extended buffer.
+	Encode(dst []byte, m Metric) []byte
+}
+
+// EtsyEncoder is the original StatsD format, prefix.name:value|type|@rate.
+// Etsy StatsD does not support tags, so they are not sent.
+type EtsyEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (EtsyEncoder) Encode(dst []byte, m Metric) []byte {
+	dst = appendName(dst, m)
+	return appendValue(dst, m)
+}
+
+// DogStatsDEncoder is the DogStatsD format, prefix.name:value|type|@rate|#key:value,key2|c:container|Ttimestamp.
+// This is the default and is the same as the EtsyEncoder for stats without tags.
+// Timestamps are only sent for counts and gauges, as DogStatsD only supports them on those.
+type DogStatsDEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (DogStatsDEncoder) Encode(dst []byte, m Metric) []byte {
+	dst = appendName(dst, m)
+	dst = appendValue(dst, m)
+	dst = appendTags(dst, m.Tags)
+
+	if m.ContainerID != "" {
+		dst = append(dst, '|', 'c', ':')
+		dst = appendSanitized(dst, m.ContainerID, "|,#\n")
+	}
+
+	if !m.Timestamp.IsZero() && (m.Type == "c" || m.Type == "g") {
+		dst = append(dst, '|', 'T')
+		dst = strconv.AppendInt(dst, m.Timestamp.Unix(), 10)
+	}
+
+	return dst
+}
+
+// MicrosecondsEncoder is the Encoder of a server that accepts the |us type,
+// such as a custom StatsD backend. None of the other dialects support it, so
+// only clients using a MicrosecondsEncoder send the Microseconds precision as
+// |us, the others send milliseconds with 3 decimals. The stats are formatted
+// by the wrapped Encoder, or the DogStatsDEncoder if it is nil.
+type MicrosecondsEncoder struct {
+	Encoder Encoder
+}
+
+// Encode implements the Encoder interface.
+func (e MicrosecondsEncoder) Encode(dst []byte, m Metric) []byte {
+	if e.Encoder == nil {
+		return DogStatsDEncoder{}.Encode(dst, m)
+	}
+
+	return e.Encoder.Encode(dst, m)
+}
+
+// InfluxDBEncoder is the Telegraf StatsD format with InfluxDB style tags,
+// prefix.name,key=value,key2=value2:value|type|@rate.
+// Telegraf ignores tags without a value.
+type InfluxDBEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (InfluxDBEncoder) Encode(dst []byte, m Metric) []byte {
+	dst = appendName(dst, m)
+	for _, tag := range m.Tags {
+		dst = append(dst, ',')
+		dst = appendKeyValue(dst, tag, ",=: |\n")
+	}
+
+	return appendValue(dst, m)
+}
+
+// GraphiteEncoder is the Graphite tagged metric format,
+// prefix.name;key=value;key2=value2:value|type|@rate.
+// Graphite ignores tags without a value.
+type GraphiteEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (GraphiteEncoder) Encode(dst []byte, m Metric) []byte {
+	dst = appendName(dst, m)
+	for _, tag := range m.Tags {
+		dst = append(dst, ';')
+		dst = appendKeyValue(dst, tag, ";=: |\n")
+	}
+
+	return appendValue(dst, m)
+}
+
+// SignalFxEncoder is the SignalFx dimensions format,
+// prefix.name[key=value,key2=value2]:value|type|@rate.
+// SignalFx ignores dimensions without a value.
+type SignalFxEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (SignalFxEncoder) Encode(dst []byte, m Metric) []byte {
+	dst = appendName(dst, m)
+	if len(m.Tags) != 0 {
+		dst = append(dst, '[')
+		for i, tag := range m.Tags {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendKeyValue(dst, tag, ",=[]: |\n")
+		}
+		dst = append(dst, ']')
+	}
+
+	return appendValue(dst, m)
+}
+
+// appendName appends the prefixed stat name.
+func appendName(dst []byte, m Metric) []byte {
+	if len(m.Prefix) != 0 {
+		dst = append(dst, m.Prefix...)
+		dst = append(dst, '.')
+	}
+
+	// This loop removes the need for the intermediate string -> []byte conversion into append
+	// dst = append(dst, []byte(m.Name)...)
+	for i := 0; i < len(m.Name); i++ {
+		dst = append(dst, m.Name[i])
+	}
+
+	return dst
+}
+
+// appendValue appends :value|type and the sample rate if less than 1.
+func appendValue(dst []byte, m Metric) []byte {
+	dst = append(dst, ':')
+	dst = append(dst, m.Value...)
+	dst = append(dst, '|')
+	dst = append(dst, m.Type...)
+
+	if m.Rate < 1 {
+		// dst = fmt.Sprintf("%s|@%f", dst, rate)
+		dst = append(dst, '|', '@')
+		dst = strconv.AppendFloat(dst, float64(m.Rate), 'f', -1, 32)
+	}
+
+	return dst
+}
+
+// appendTags appends the tags in the DogStatsD format, |#key:value,key2,
+// replacing the reserved characters so they can not break the line.
+func appendTags(dst []byte, tags []string) []byte {
+	if len(tags) == 0 {
+		return dst
+	}
+
+	dst = append(dst, '|', '#')
+	for i, tag := range tags {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+
+		dst = appendSanitized(dst, tag, "|,#\n")
+	}
+
+	return dst
+}
+
+// appendKeyValue appends a DogStatsD "key:value" tag as key=value,
+// with the reserved characters replaced in both the key and value.
+func appendKeyValue(dst []byte, tag string, reserved string) []byte {
+	for i := 0; i < len(tag); i++ {
+		if tag[i] == ':' {
+			dst = appendSanitized(dst, tag[:i], reserved)
+			dst = append(dst, '=')
+			return appendSanitized(dst, tag[i+1:], reserved)
+		}
+	}
+
+	return appendSanitized(dst, tag, reserved)
+}
+
+// appendSanitized appends s with any of the reserved characters replaced with '_'.
+func appendSanitized(dst []byte, s string, reserved string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		for j := 0; j < len(reserved); j++ {
+			if c == reserved[j] {
+				c = '_'
+				break
+			}
+		}
+
+		dst = append(dst, c)
+	}
+
+	return dst
+}
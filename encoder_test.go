@@ -0,0 +1,116 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncoders(t *testing.T) {
+	m := Metric{
+		Prefix: []byte("prefix"),
+		Name:   "stat",
+		Value:  []byte("5"),
+		Type:   "c",
+		Rate:   0.5,
+		Tags:   []string{"env:prod", "canary", "route:/a,b=c"},
+	}
+
+	cases := []struct {
+		encoder  Encoder
+		expected string
+	}{
+		{EtsyEncoder{}, "prefix.stat:5|c|@0.5"},
+		{DogStatsDEncoder{}, "prefix.stat:5|c|@0.5|#env:prod,canary,route:/a_b=c"},
+		{InfluxDBEncoder{}, "prefix.stat,env=prod,canary,route=/a_b_c:5|c|@0.5"},
+		{GraphiteEncoder{}, "prefix.stat;env=prod;canary;route=/a,b_c:5|c|@0.5"},
+		{SignalFxEncoder{}, "prefix.stat[env=prod,canary,route=/a_b_c]:5|c|@0.5"},
+	}
+
+	for _, tc := range cases {
+		if b := string(tc.encoder.Encode(nil, m)); b != tc.expected {
+			t.Errorf("%T: expected %s, got %s", tc.encoder, tc.expected, b)
+		}
+	}
+
+	// without prefix, tags or rate
+	m = Metric{Name: "stat", Value: []byte("1.5"), Type: "g", Rate: 1}
+	for _, tc := range cases {
+		expected := "stat:1.5|g"
+		if b := string(tc.encoder.Encode(nil, m)); b != expected {
+			t.Errorf("%T: expected %s, got %s", tc.encoder, expected, b)
+		}
+	}
+}
+
+func TestEncodersTimestamp(t *testing.T) {
+	m := Metric{
+		Name:      "stat",
+		Value:     []byte("5"),
+		Type:      "c",
+		Rate:      1,
+		Tags:      []string{"env:prod"},
+		Timestamp: time.Unix(1400000000, 0),
+	}
+
+	expected := "stat:5|c|#env:prod|T1400000000"
+	if b := string(DogStatsDEncoder{}.Encode(nil, m)); b != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	// only counts and gauges
+	m.Type = "ms"
+	expected = "stat:5|ms|#env:prod"
+	if b := string(DogStatsDEncoder{}.Encode(nil, m)); b != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	// ignored by the other dialects
+	m.Type = "g"
+	expected = "stat:5|g"
+	if b := string(EtsyEncoder{}.Encode(nil, m)); b != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestClientEncoder(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+	c.encoder = InfluxDBEncoder{}
+
+	err := c.WithTags("env:prod").Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "prefix.count,env=prod:1|c"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// substaters share the encoder
+	buf.Reset()
+	err = c.Substater("sub").Gauge("gauge", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "prefix.sub.gauge:0|g\nprefix.sub.gauge:-1|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestWithEncoder(t *testing.T) {
+	c, err := NewWithOptions("0.0.0.0:1000", WithPrefix("prefix"), WithEncoder(GraphiteEncoder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, ok := c.encoder.(GraphiteEncoder); !ok {
+		t.Errorf("incorrect encoder, got %T", c.encoder)
+	}
+
+	if p := c.prefix; string(p) != "prefix" {
+		t.Errorf("incorrect prefix, got %v", string(p))
+	}
+}
@@ -0,0 +1,133 @@
+package statsd
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrEventTitle is returned when sending an event without a title.
+var ErrEventTitle = errors.New("event title is required")
+
+// EventPriority is the priority of a DogStatsD event.
+type EventPriority string
+
+// EventAlertType is the alert type of a DogStatsD event.
+type EventAlertType string
+
+// Event priorities, the server defaults to normal.
+const (
+	PriorityNormal EventPriority = "normal"
+	PriorityLow    EventPriority = "low"
+)
+
+// Event alert types, the server defaults to info.
+const (
+	AlertInfo    EventAlertType = "info"
+	AlertWarning EventAlertType = "warning"
+	AlertError   EventAlertType = "error"
+	AlertSuccess EventAlertType = "success"
+)
+
+// Event is a DogStatsD event, shown as an annotation on dashboards.
+// Only the Title is required, empty fields are not sent.
+type Event struct {
+	Title string
+	Text  string
+
+	// Timestamp is when the event happened, the server uses the arrival time if zero.
+	Timestamp      time.Time
+	Hostname       string
+	AggregationKey string
+	Priority       EventPriority
+	SourceType     string
+	AlertType      EventAlertType
+
+	// Tags are sent after the client's Tags.
+	Tags []string
+}
+
+// Event sends the event to the server using the DogStatsD format,
+// _e{title.length,text.length}:title|text|d:timestamp|h:hostname|p:priority|t:alert_type|k:aggregation_key|s:source_type|#tags
+// Events are not prefixed or sampled, but include the client's Tags.
+func (client *RemoteClient) Event(e *Event) error {
+	if e.Title == "" {
+		return ErrEventTitle
+	}
+
+	title := escapeEventText(e.Title)
+	text := escapeEventText(e.Text)
+
+	message := make([]byte, 0, len(title)+len(text)+64)
+	message = append(message, "_e{"...)
+	message = strconv.AppendInt(message, int64(len(title)), 10)
+	message = append(message, ',')
+	message = strconv.AppendInt(message, int64(len(text)), 10)
+	message = append(message, "}:"...)
+	message = append(message, title...)
+	message = append(message, '|')
+	message = append(message, text...)
+
+	if !e.Timestamp.IsZero() {
+		message = append(message, "|d:"...)
+		message = strconv.AppendInt(message, e.Timestamp.Unix(), 10)
+	}
+
+	message = appendEventField(message, "|h:", e.Hostname)
+	message = appendEventField(message, "|p:", string(e.Priority))
+	message = appendEventField(message, "|t:", string(e.AlertType))
+	message = appendEventField(message, "|k:", e.AggregationKey)
+	message = appendEventField(message, "|s:", e.SourceType)
+
+	tags := client.Tags
+	if len(e.Tags) != 0 {
+		tags = make([]string, 0, len(client.Tags)+len(e.Tags))
+		tags = append(tags, client.Tags...)
+		tags = append(tags, e.Tags...)
+	}
+
+	message = appendTags(message, tags)
+
+	return client.write(message)
+}
+
+// Event on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) Event(e *Event) error {
+	return nil
+}
+
+// appendEventField appends the key and value if the value is set,
+// with any characters that would end the field replaced.
+func appendEventField(message []byte, key string, value string) []byte {
+	if value == "" {
+		return message
+	}
+
+	message = append(message, key...)
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '|', '\n':
+			message = append(message, '_')
+		default:
+			message = append(message, c)
+		}
+	}
+
+	return message
+}
+
+// escapeEventText escapes new lines, as the lengths sent
+// must match the escaped title and text.
+func escapeEventText(s string) []byte {
+	escaped := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			escaped = append(escaped, '\\', 'n')
+			continue
+		}
+
+		escaped = append(escaped, s[i])
+	}
+
+	return escaped
+}
@@ -0,0 +1,68 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientEvent(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+
+	err := c.Event(&Event{Title: "deploy", Text: "v1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "_e{6,6}:deploy|v1.2.3"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// all the fields
+	buf.Reset()
+	c.Tags = []string{"env:prod"}
+	err = c.Event(&Event{
+		Title:          "failover",
+		Text:           "primary down\nusing standby",
+		Timestamp:      time.Unix(1400000000, 0),
+		Hostname:       "db1",
+		AggregationKey: "db",
+		Priority:       PriorityLow,
+		SourceType:     "postgres",
+		AlertType:      AlertWarning,
+		Tags:           []string{"role:primary"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `_e{8,27}:failover|primary down\nusing standby|d:1400000000|h:db1|p:low|t:warning|k:db|s:postgres|#env:prod,role:primary`
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// title required
+	buf.Reset()
+	err = c.Event(&Event{Text: "text"})
+	if err != ErrEventTitle {
+		t.Fatalf("expected ErrEventTitle, got %v", err)
+	}
+
+	if b := buf.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+
+	// closed connection
+	c.Close()
+	err = c.Event(&Event{Title: "deploy"})
+	if err != ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestNoopClientEvent(t *testing.T) {
+	var s Stater = NoopClient{}
+	if err := s.Event(&Event{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,163 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// failbackProbeTimeout is how long to wait for a refused error when probing a UDP
+// endpoint. No error by then means the ICMP port unreachable never came back.
+const failbackProbeTimeout = 100 * time.Millisecond
+
+// WithFailover adds standby endpoints, in order, after the primary address. When
+// writing to the active endpoint fails, such as a TCP error or, for UDP, the server
+// refusing the previous packet, the client reconnects to the next endpoint that can
+// be reached. Every failbackInterval the primary is probed and, once it is reachable
+// again, the client fails back to it. The standby addresses use the same network
+// as the primary, the network prefix is optional.
+func WithFailover(failbackInterval time.Duration, standby ...string) Option {
+	return func(client *RemoteClient) {
+		client.endpoints = append([]string{client.address}, standby...)
+		client.failbackInterval = failbackInterval
+	}
+}
+
+// ActiveAddress returns the address of the endpoint the client is currently sending to.
+func (client *RemoteClient) ActiveAddress() string {
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	return client.address
+}
+
+// setEndpoints removes the network prefix from the standby endpoints,
+// checking they use the same network as the primary.
+func (client *RemoteClient) setEndpoints() error {
+	for i := 1; i < len(client.endpoints); i++ {
+		endpoint := client.endpoints[i]
+		if !strings.Contains(endpoint, "://") {
+			continue
+		}
+
+		network, address, err := splitNetwork(endpoint)
+		if err != nil {
+			return err
+		}
+
+		if network != client.network {
+			return fmt.Errorf("standby %s does not use the primary's network %s", endpoint, client.network)
+		}
+
+		client.endpoints[i] = address
+	}
+
+	return nil
+}
+
+// connectEndpoint connects to the first endpoint that can be dialed, starting
+// with the active one when first connecting, or the one after it when reconnecting
+// after an error. The endpoints are dialed without the writeMutex held.
+func (client *RemoteClient) connectEndpoint() error {
+	client.writeMutex.Lock()
+	start := client.active
+	if client.transport != nil {
+		start++
+	}
+	client.writeMutex.Unlock()
+
+	var err error
+	for i := 0; i < len(client.endpoints); i++ {
+		active := (start + i) % len(client.endpoints)
+		address := client.endpoints[active]
+
+		var conn net.Conn
+		conn, err = client.dial(address, hostname(address))
+		if err != nil {
+			continue
+		}
+
+		client.writeMutex.Lock()
+		defer client.writeMutex.Unlock()
+
+		if client.closed() {
+			conn.Close()
+			return ErrConnectionClosed
+		}
+
+		client.setConn(conn)
+		client.active = active
+		client.address = address
+
+		return nil
+	}
+
+	return err
+}
+
+// failback probes the primary endpoint every failbackInterval, while not active,
+// and switches back to it once reachable, until the connection is closed.
+func (client *RemoteClient) failback() {
+	ticker := time.NewTicker(client.failbackInterval)
+	defer ticker.Stop()
+
+	primary := client.endpoints[0]
+	for {
+		select {
+		case <-ticker.C:
+		case <-client.done:
+			return
+		}
+
+		if client.ActiveAddress() == primary {
+			continue
+		}
+
+		conn, err := client.probe(primary)
+		if err != nil {
+			continue
+		}
+
+		client.writeMutex.Lock()
+		if client.transport == nil {
+			conn.Close()
+		} else {
+			client.transport.Flush()
+			client.setConn(conn)
+			client.active = 0
+			client.address = primary
+		}
+		client.writeMutex.Unlock()
+	}
+}
+
+// probe returns a connection to the address if it is reachable. Dialing UDP
+// always works, so an empty packet is sent and any refused error is waited for.
+func (client *RemoteClient) probe(address string) (net.Conn, error) {
+	conn, err := client.dial(address, hostname(address))
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(client.network, "udp") {
+		return conn, nil
+	}
+
+	_, err = conn.Write(nil)
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(failbackProbeTimeout))
+		_, err = conn.Read(make([]byte, 1))
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	var netErr net.Error
+	if err == nil || (errors.As(err, &netErr) && netErr.Timeout() && !errors.Is(err, syscall.ECONNREFUSED)) {
+		return conn, nil
+	}
+
+	conn.Close()
+	return nil, err
+}
@@ -0,0 +1,175 @@
+package statsd
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// unusedAddress returns a local address that nothing is listening on.
+func unusedAddress(t *testing.T, network string) string {
+	t.Helper()
+
+	var address string
+	if network == "udp" {
+		l, err := net.ListenPacket(network, "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address = l.LocalAddr().String()
+		l.Close()
+	} else {
+		l, err := net.Listen(network, "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address = l.Addr().String()
+		l.Close()
+	}
+
+	return address
+}
+
+func TestWithFailoverUDP(t *testing.T) {
+	primary := unusedAddress(t, "udp")
+
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := NewWithOptions(primary, WithReconnectDelay(0), WithFailover(0, "udp://"+l.LocalAddr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if a := c.ActiveAddress(); a != primary {
+		t.Fatalf("expected %s, got %s", primary, a)
+	}
+
+	// the primary refuses the packets, so the next write moves to the standby
+	var n int
+	b := make([]byte, 1024)
+	for i := 0; i < 100; i++ {
+		c.Count("count")
+
+		l.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+		n, _, err = l.ReadFrom(b)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		t.Fatal("should have failed over to the standby")
+	}
+
+	if p, e := string(b[:n]), "count:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
+	}
+
+	if a, e := c.ActiveAddress(), l.LocalAddr().String(); a != e {
+		t.Fatalf("expected %s, got %s", e, a)
+	}
+}
+
+func TestWithFailoverTCP(t *testing.T) {
+	primary := unusedAddress(t, "tcp")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	// the primary is down from the start
+	c, err := NewWithOptions("tcp://"+primary, WithFailover(0, l.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if a, e := c.ActiveAddress(), l.Addr().String(); a != e {
+		t.Fatalf("expected %s, got %s", e, a)
+	}
+
+	c.Count("count")
+
+	conn := <-conns
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := "count:1|c\n"; line != e {
+		t.Fatalf("expected %s, got %s", e, line)
+	}
+}
+
+func TestWithFailoverFailback(t *testing.T) {
+	primary := unusedAddress(t, "tcp")
+
+	standby, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer standby.Close()
+
+	c, err := NewWithOptions("tcp://"+primary, WithFailover(5*time.Millisecond, standby.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if a, e := c.ActiveAddress(), standby.Addr().String(); a != e {
+		t.Fatalf("expected %s, got %s", e, a)
+	}
+
+	// the primary comes back
+	l, err := net.Listen("tcp", primary)
+	if err != nil {
+		t.Skipf("can not listen on the primary again: %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	for i := 0; i < 100 && c.ActiveAddress() != primary; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if a := c.ActiveAddress(); a != primary {
+		t.Fatalf("expected %s, got %s", primary, a)
+	}
+}
+
+func TestWithFailoverNetwork(t *testing.T) {
+	_, err := NewWithOptions("tcp://127.0.0.1:1000", WithFailover(0, "udp://127.0.0.1:1001"))
+	if err == nil {
+		t.Fatal("should not allow standbys on a different network")
+	}
+}
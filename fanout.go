@@ -0,0 +1,242 @@
+package statsd
+
+import (
+	"errors"
+	"time"
+)
+
+// FanOutClient implements Stater by sending every stat to all of its children,
+// such as when migrating between servers or dialects. Stats are sampled once, so
+// every child sees the same samples, and errors from the children are joined.
+type FanOutClient struct {
+	DefaultRate float32
+
+	children []Stater
+
+	// sampledChildren are the children that send stats without sampling them again.
+	sampledChildren []Stater
+
+	// sampled is set on a FanOutClient nested in another, which has already
+	// sampled the stats, so they are sent to the children but not sampled again.
+	sampled bool
+}
+
+// presampler is implemented by the Staters that can send the stats sampled by a FanOutClient.
+type presampler interface {
+	// presampled returns a Stater that sends stats with their rate but does not sample them.
+	presampled() Stater
+}
+
+// NewFanOut returns a FanOutClient sending every stat to each of the children.
+// Children other than a RemoteClient, ShardedClient or FanOutClient also
+// sample the stats, as the FanOutClient can not tell them they were sampled.
+func NewFanOut(children ...Stater) *FanOutClient {
+	client := &FanOutClient{children: children}
+	for _, child := range children {
+		if p, ok := child.(presampler); ok {
+			child = p.presampled()
+		}
+
+		client.sampledChildren = append(client.sampledChildren, child)
+	}
+
+	return client
+}
+
+// presampled implements the presampler interface.
+func (client *RemoteClient) presampled() Stater {
+	newClient := *client
+	newClient.sampled = true
+
+	return &newClient
+}
+
+// presampled implements the presampler interface.
+func (client *ShardedClient) presampled() Stater {
+	newClient := &ShardedClient{ring: client.ring}
+	for _, shard := range client.shards {
+		newClient.shards = append(newClient.shards, shard.presampled().(*RemoteClient))
+	}
+
+	return newClient
+}
+
+// presampled implements the presampler interface.
+func (client *FanOutClient) presampled() Stater {
+	newClient := NewFanOut(client.sampledChildren...)
+	newClient.DefaultRate = client.DefaultRate
+	newClient.sampled = true
+
+	return newClient
+}
+
+// sample returns the rate to send to the children, the provided rate, or the client's
+// DefaultRate, or the global statsd.DefaultRate if the client's is zero, and true
+// if the stat should be sent.
+func (client *FanOutClient) sample(rate []float32) (float32, bool) {
+	r := DefaultRate
+	if len(rate) > 0 {
+		r = rate[0]
+	} else if client.DefaultRate != 0 {
+		r = client.DefaultRate
+	}
+
+	if r == 0 {
+		return r, false
+	}
+
+	if r < 1 && !client.sampled {
+		randLock.Lock() // rand objects are not thread safe.
+		sample := randSource.Float32()
+		randLock.Unlock()
+
+		if sample >= r {
+			return r, false
+		}
+	}
+
+	return r, true
+}
+
+// each calls f with each child, joining the errors.
+func each(children []Stater, f func(Stater) error) error {
+	var errs []error
+	for _, child := range children {
+		if err := f(child); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Count adds 1 to the provided stat on every child.
+func (client *FanOutClient) Count(stat string, rate ...float32) error {
+	return client.CountMultiple(stat, 1, rate...)
+}
+
+// CountMultiple adds `count` to the provided stat on every child.
+func (client *FanOutClient) CountMultiple(stat string, count int, rate ...float32) error {
+	r, ok := client.sample(rate)
+	if !ok {
+		return nil
+	}
+
+	return each(client.sampledChildren, func(s Stater) error { return s.CountMultiple(stat, count, r) })
+}
+
+// Measure reports a duration to the provided stat on every child.
+func (client *FanOutClient) Measure(stat string, delta time.Duration, rate ...float32) error {
+	r, ok := client.sample(rate)
+	if !ok {
+		return nil
+	}
+
+	return each(client.sampledChildren, func(s Stater) error { return s.Measure(stat, delta, r) })
+}
+
+// Histogram reports an arbitrary value to the provided stat on every child.
+func (client *FanOutClient) Histogram(stat string, value float64, rate ...float32) error {
+	r, ok := client.sample(rate)
+	if !ok {
+		return nil
+	}
+
+	return each(client.sampledChildren, func(s Stater) error { return s.Histogram(stat, value, r) })
+}
+
+// Distribution reports an arbitrary value to the provided stat on every child.
+func (client *FanOutClient) Distribution(stat string, value float64, rate ...float32) error {
+	r, ok := client.sample(rate)
+	if !ok {
+		return nil
+	}
+
+	return each(client.sampledChildren, func(s Stater) error { return s.Distribution(stat, value, r) })
+}
+
+// Gauge sets the gauge on every child.
+func (client *FanOutClient) Gauge(stat string, value interface{}) error {
+	return each(client.children, func(s Stater) error { return s.Gauge(stat, value) })
+}
+
+// GaugeInt64 sets the gauge on every child.
+func (client *FanOutClient) GaugeInt64(stat string, value int64) error {
+	return each(client.children, func(s Stater) error { return s.GaugeInt64(stat, value) })
+}
+
+// GaugeUint64 sets the gauge on every child.
+func (client *FanOutClient) GaugeUint64(stat string, value uint64) error {
+	return each(client.children, func(s Stater) error { return s.GaugeUint64(stat, value) })
+}
+
+// GaugeFloat64 sets the gauge on every child.
+func (client *FanOutClient) GaugeFloat64(stat string, value float64) error {
+	return each(client.children, func(s Stater) error { return s.GaugeFloat64(stat, value) })
+}
+
+// GaugeAbsolute sets the gauge on every child.
+func (client *FanOutClient) GaugeAbsolute(stat string, value float64) error {
+	return each(client.children, func(s Stater) error { return s.GaugeAbsolute(stat, value) })
+}
+
+// GaugeDelta changes the gauge on every child.
+func (client *FanOutClient) GaugeDelta(stat string, delta float64) error {
+	return each(client.children, func(s Stater) error { return s.GaugeDelta(stat, delta) })
+}
+
+// Set adds the value to the set on every child.
+func (client *FanOutClient) Set(stat string, value string) error {
+	return each(client.children, func(s Stater) error { return s.Set(stat, value) })
+}
+
+// Event sends the event to every child.
+func (client *FanOutClient) Event(e *Event) error {
+	return each(client.children, func(s Stater) error { return s.Event(e) })
+}
+
+// ServiceCheck sends the service check to every child.
+func (client *FanOutClient) ServiceCheck(name string, status ServiceCheckStatus, options *ServiceCheckOptions) error {
+	return each(client.children, func(s Stater) error { return s.ServiceCheck(name, status, options) })
+}
+
+// Substater returns another FanOutClient with the substater of each child.
+func (client *FanOutClient) Substater(extraPrefix ...string) Stater {
+	children := make([]Stater, 0, len(client.children))
+	for _, child := range client.children {
+		children = append(children, child.Substater(extraPrefix...))
+	}
+
+	newClient := NewFanOut(children...)
+	newClient.DefaultRate = client.DefaultRate
+	newClient.sampled = client.sampled
+
+	return newClient
+}
+
+// WithTags returns another FanOutClient adding the tags on each child.
+func (client *FanOutClient) WithTags(tags ...string) Stater {
+	children := make([]Stater, 0, len(client.children))
+	for _, child := range client.children {
+		children = append(children, child.WithTags(tags...))
+	}
+
+	newClient := NewFanOut(children...)
+	newClient.DefaultRate = client.DefaultRate
+	newClient.sampled = client.sampled
+
+	return newClient
+}
+
+// SetDefaultRate sets the default rate for the stater and every child.
+func (client *FanOutClient) SetDefaultRate(rate float32) {
+	client.DefaultRate = rate
+	for _, child := range client.children {
+		child.SetDefaultRate(rate)
+	}
+}
+
+// Close closes every child, joining the errors.
+func (client *FanOutClient) Close() error {
+	return each(client.children, func(s Stater) error { return s.Close() })
+}
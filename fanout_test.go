@@ -0,0 +1,140 @@
+package statsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingStater returns err from Count and Close.
+type failingStater struct {
+	NoopClient
+	err error
+}
+
+func (s failingStater) CountMultiple(stat string, count int, rate ...float32) error {
+	return s.err
+}
+
+func (s failingStater) Close() error {
+	return s.err
+}
+
+func TestFanOutClient(t *testing.T) {
+	// This will not compile if FanOutClient does not implement the Stater interface.
+	var _ Stater = &FanOutClient{}
+
+	c1, buf1 := NewTestClient("first")
+	c2, buf2 := NewTestClient("second")
+	c2.encoder = InfluxDBEncoder{}
+
+	c := NewFanOut(c1, c2)
+
+	err := c.WithTags("env:prod").Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "first.count:1|c|#env:prod"
+	if b := buf1.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	expected = "second.count,env=prod:1|c"
+	if b := buf2.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// substaters
+	buf1.Reset()
+	buf2.Reset()
+	err = c.Substater("sub").Gauge("gauge", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "first.sub.gauge:1|g"
+	if b := buf1.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	expected = "second.sub.gauge:1|g"
+	if b := buf2.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// default rate
+	c.SetDefaultRate(0.5)
+	if c1.DefaultRate != 0.5 || c2.DefaultRate != 0.5 {
+		t.Errorf("should set the default rate on the children, got %v and %v", c1.DefaultRate, c2.DefaultRate)
+	}
+}
+
+func TestFanOutClientSampling(t *testing.T) {
+	c1, buf1 := NewTestClient("")
+	c2, buf2 := NewTestClient("")
+	c := NewFanOut(c1, c2.Substater("").WithTags())
+
+	for i := 0; i < 1000; i++ {
+		c.Measure("timing", time.Millisecond, 0.5)
+	}
+
+	// the same samples are sent to both, with the rate
+	if buf1.String() != buf2.String() {
+		t.Fatal("should send the same samples to every child")
+	}
+
+	n := strings.Count(buf1.String(), "timing:1|ms|@0.5")
+	if n == 0 || n == 1000 {
+		t.Errorf("should have sampled the stats, got %d", n)
+	}
+
+	// zero rate
+	buf1.Reset()
+	c.Count("count", 0)
+	if b := buf1.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+}
+
+func TestFanOutClientNestedSampling(t *testing.T) {
+	c1, buf1 := NewTestClient("")
+	c2, buf2 := NewTestClient("")
+	c := NewFanOut(NewFanOut(c1, c2).WithTags())
+
+	for i := 0; i < 4000; i++ {
+		c.Count("count", 0.5)
+	}
+
+	if buf1.String() != buf2.String() {
+		t.Fatal("should send the same samples to every child")
+	}
+
+	// the stats are only sampled once by the outer client
+	n := strings.Count(buf1.String(), "count:1|c|@0.5")
+	if n < 1700 || n > 2300 {
+		t.Errorf("expected about 2000 stats, got %d", n)
+	}
+}
+
+func TestFanOutClientErrors(t *testing.T) {
+	err1 := errors.New("first")
+	err2 := errors.New("second")
+
+	c := NewFanOut(failingStater{err: err1}, NoopClient{}, failingStater{err: err2})
+
+	err := c.Count("count")
+	if !errors.Is(err, err1) || !errors.Is(err, err2) {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+
+	err = c.Close()
+	if !errors.Is(err, err1) || !errors.Is(err, err2) {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+
+	if err := c.Gauge("gauge", 1); err != nil {
+		t.Fatal(err)
+	}
+}
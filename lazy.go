@@ -0,0 +1,19 @@
+package statsd
+
+// WithLazyConnect returns a usable client even if it can not connect to the server
+// yet, for example if the host does not resolve until DNS is ready. Until connected
+// stats are dropped, returning ErrNotConnected, and counted by Dropped. The client
+// retries connecting in the background, backing off like any reconnect, and
+// stats are not held up while it dials.
+// Invalid addresses and options still return an error.
+func WithLazyConnect() Option {
+	return func(client *RemoteClient) {
+		client.lazy = true
+	}
+}
+
+// Dropped returns the number of stats dropped because a client created with
+// WithLazyConnect had not connected yet, including those from substaters.
+func (client *RemoteClient) Dropped() uint64 {
+	return client.dropped.Load()
+}
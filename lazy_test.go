@@ -0,0 +1,141 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithLazyConnect(t *testing.T) {
+	address := unusedAddress(t, "tcp")
+
+	c, err := NewWithOptions("tcp://"+address, WithLazyConnect(), WithReconnectDelay(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// dropped until connected
+	err = c.Count("count")
+	if err != ErrNotConnected {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+
+	c.Substater("sub").Count("count")
+	if d := c.Dropped(); d != 2 {
+		t.Fatalf("expected 2 dropped, got %d", d)
+	}
+
+	// the server starts
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("can not listen on the address again: %v", err)
+	}
+	defer l.Close()
+
+	for i := 0; i < 200; i++ {
+		err = c.Count("count")
+		if err != ErrNotConnected {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatalf("should have connected, got %v", err)
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := "count:1|c\n"; line != e {
+		t.Fatalf("expected %s, got %s", e, line)
+	}
+}
+
+func TestWithLazyConnectClose(t *testing.T) {
+	c, err := NewWithOptions("tcp://"+unusedAddress(t, "tcp"), WithLazyConnect())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Count("count")
+	if err != ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	}
+
+	if d := c.Dropped(); d != 0 {
+		t.Fatalf("expected 0 dropped, got %d", d)
+	}
+
+	// from a url
+	c, err = NewFromURL("tcp://" + unusedAddress(t, "tcp") + "?lazy=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// invalid options are still an error
+	_, err = NewWithOptions("unixgram:///var/run/statsd.sock", WithLazyConnect(), WithTLS(&tls.Config{}))
+	if err == nil {
+		t.Fatal("should not allow TLS over unixgram")
+	}
+}
+
+func TestWithLazyConnectDoesNotBlockWrites(t *testing.T) {
+	// the first dial fails, the retry hangs
+	var calls int32
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			close(dialing)
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+		}
+		return nil, errors.New("unreachable")
+	}
+
+	c, err := NewWithOptions("tcp://127.0.0.1:8125", WithLazyConnect(), WithDialFunc(dial), WithReconnectDelay(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	defer close(release)
+
+	select {
+	case <-dialing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("should have retried connecting")
+	}
+
+	// stats are dropped while connecting, rather than waiting for the dial
+	start := time.Now()
+	err = c.Count("count")
+	if err != ErrNotConnected {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("should not wait for the dial, took %v", d)
+	}
+}
@@ -0,0 +1,194 @@
+package statsd
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPacketSize is the most bytes of packed stats sent together,
+// small enough to fit in a single UDP packet on most networks.
+const maxPacketSize = 1432
+
+// WithPacking buffers timings, histograms and distributions and sends all the
+// values of a stat in a single line, name:v1:v2:v3|ms, every interval or when
+// Flush is called. This cuts the number of packets when the same stats are
+// recorded many times, but the server must support multiple values per line,
+// such as DogStatsD. Other stats are still sent right away.
+func WithPacking(interval time.Duration) Option {
+	return func(client *RemoteClient) {
+		client.packer = &packer{stats: make(map[string]*packedStat)}
+		client.packInterval = interval
+	}
+}
+
+// packer collects the values of stats with the same name, type, rate and tags.
+type packer struct {
+	mutex sync.Mutex
+	stats map[string]*packedStat
+	order []*packedStat
+	key   []byte
+}
+
+type packedStat struct {
+	metric Metric
+	values []byte // v1:v2:v3
+}
+
+// packable returns true if multiple values of the type can be sent in one line.
+func packable(metricType string) bool {
+	switch metricType {
+	case "ms", "us", "h", "d":
+		return true
+	}
+
+	return false
+}
+
+// MeasureMultiple reports many durations for the provided stat (plus the prefix)
+// in a single line, name:v1:v2:v3|ms, using the client's Precision.
+// The server must support multiple values per line, such as DogStatsD.
+// Rate is optional and applies to each of the durations, which are sampled together.
+func (client *RemoteClient) MeasureMultiple(stat string, deltas []time.Duration, rate ...float32) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	r := client.rate(rate)
+	metricType := "ms"
+
+	data := client.buffer(stat, 24*len(deltas))
+	for i, delta := range deltas {
+		if i > 0 {
+			data = append(data, ':')
+		}
+
+		data, metricType = client.appendDuration(data, delta)
+	}
+
+	return client.submit(stat, data, metricType, r)
+}
+
+// pack adds the metric's value to the pending values for the stat. If there is
+// no more room in the line, the values so far are sent first.
+func (client *RemoteClient) pack(m Metric) error {
+	value := m.Value
+	m.Value = nil
+
+	p := client.packer
+	p.mutex.Lock()
+
+	// stats are packed together if everything but the value is the same.
+	p.key = appendName(p.key[:0], m)
+	p.key = append(p.key, '|')
+	p.key = append(p.key, m.Type...)
+	p.key = append(p.key, '|')
+	p.key = strconv.AppendUint(p.key, uint64(math.Float32bits(m.Rate)), 16)
+	p.key = append(p.key, '|')
+	p.key = strconv.AppendInt(p.key, m.Timestamp.UnixNano(), 16)
+	for _, tag := range m.Tags {
+		// length prefixed, so tags containing '|' can not match other tags.
+		p.key = append(p.key, '|')
+		p.key = strconv.AppendInt(p.key, int64(len(tag)), 10)
+		p.key = append(p.key, ':')
+		p.key = append(p.key, tag...)
+	}
+
+	ps, ok := p.stats[string(p.key)]
+	if !ok {
+		ps = &packedStat{metric: m}
+		p.stats[string(p.key)] = ps
+		p.order = append(p.order, ps)
+	}
+
+	// the key is about the size of the rest of the line.
+	var full []byte
+	if len(ps.values) != 0 && len(p.key)+len(ps.values)+1+len(value) > maxPacketSize {
+		full = client.encodePacked(nil, ps)
+		ps.values = ps.values[:0]
+	}
+
+	if len(ps.values) != 0 {
+		ps.values = append(ps.values, ':')
+	}
+	ps.values = append(ps.values, value...)
+	p.mutex.Unlock()
+
+	if full != nil {
+		return client.write(full)
+	}
+
+	return nil
+}
+
+// Flush sends any values waiting to be packed, several stats per packet.
+// It does nothing if packing is not enabled.
+func (client *RemoteClient) Flush() error {
+	return client.FlushContext(context.Background())
+}
+
+// FlushContext is Flush, but stops waiting for a blocked write when the
+// context ends, returning its error. Values not sent by then are dropped.
+func (client *RemoteClient) FlushContext(ctx context.Context) error {
+	p := client.packer
+	if p == nil {
+		return nil
+	}
+
+	p.mutex.Lock()
+	order := p.order
+	p.stats = make(map[string]*packedStat, len(order))
+	p.order = nil
+	p.mutex.Unlock()
+
+	var err error
+	var message []byte
+	for _, ps := range order {
+		line := client.encodePacked(nil, ps)
+
+		if len(message) != 0 && len(message)+1+len(line) > maxPacketSize {
+			if e := client.writeContext(ctx, message); e != nil {
+				err = e
+			}
+			message = message[:0]
+		}
+
+		if len(message) != 0 {
+			message = append(message, '\n')
+		}
+		message = append(message, line...)
+	}
+
+	if len(message) != 0 {
+		if e := client.writeContext(ctx, message); e != nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// encodePacked appends the line with all the stat's values.
+func (client *RemoteClient) encodePacked(dst []byte, ps *packedStat) []byte {
+	m := ps.metric
+	m.Value = ps.values
+
+	return client.encode(dst, m)
+}
+
+// flushPacked flushes the packed values every interval until the connection is closed.
+func (client *RemoteClient) flushPacked() {
+	ticker := time.NewTicker(client.packInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			client.Flush()
+		case <-client.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientMeasureMultiple(t *testing.T) {
+	c, buf := NewTestClient("test")
+
+	err := c.MeasureMultiple("timing", []time.Duration{time.Millisecond, 2 * time.Millisecond, 3500 * time.Microsecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test.timing:1:2:3|ms"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// with rate and precision
+	buf.Reset()
+	c.Precision = FractionalMilliseconds(1)
+	err = c.MeasureMultiple("timing", []time.Duration{time.Millisecond, 3500 * time.Microsecond}, 0.999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.timing:1:3.5|ms|@0.999999"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// nothing to send
+	buf.Reset()
+	err = c.MeasureMultiple("timing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := buf.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+}
+
+func TestClientPacking(t *testing.T) {
+	c, buf := NewTestClient("test")
+	WithPacking(0)(c)
+
+	c.Measure("timing", time.Millisecond)
+	c.Measure("timing", 2*time.Millisecond)
+	c.Histogram("size", 10)
+	c.WithTags("env:prod").Measure("timing", 3*time.Millisecond)
+	c.Measure("timing", 4*time.Millisecond)
+	c.Distribution("size", 5)
+
+	// not packed
+	err := c.Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test.count:1|c"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.timing:1:2:4|ms\ntest.size:10|h\ntest.timing:3|ms|#env:prod\ntest.size:5|d"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// tags that encode differently are not packed together
+	buf.Reset()
+	c.WithTags("a|b").Measure("timing", time.Millisecond)
+	c.WithTags("a", "b").Measure("timing", 2*time.Millisecond)
+	c.Flush()
+
+	expected = "test.timing:1|ms|#a_b\ntest.timing:2|ms|#a,b"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// nothing left
+	buf.Reset()
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := buf.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+}
+
+func TestClientPackingFullLine(t *testing.T) {
+	c, buf := NewTestClient("test")
+	WithPacking(0)(c)
+
+	for i := 0; i < 1000; i++ {
+		c.Histogram("size", 123)
+	}
+
+	// full lines are sent right away
+	if buf.Len() == 0 {
+		t.Fatal("should have written the full lines")
+	}
+
+	c.Flush()
+
+	// each write is a line ending in |h
+	values := 0
+	lines := strings.SplitAfter(buf.String(), "|h")
+	for _, line := range lines[:len(lines)-1] {
+		if len(line) > maxPacketSize {
+			t.Errorf("line too long, got %d bytes", len(line))
+		}
+
+		if !strings.HasPrefix(line, "test.size:") {
+			t.Fatalf("incorrect line, got %s", line)
+		}
+
+		values += strings.Count(line, ":")
+	}
+
+	if len(lines) < 3 || lines[len(lines)-1] != "" {
+		t.Errorf("expected several lines, got %v", lines)
+	}
+
+	if values != 1000 {
+		t.Errorf("expected 1000 values, got %d", values)
+	}
+}
+
+func TestWithPacking(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := NewWithOptions(l.LocalAddr().String(), WithPrefix("test"), WithPacking(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Measure("timing", time.Millisecond)
+	c.Substater("sub").Measure("timing", 2*time.Millisecond)
+	c.Measure("timing", 3*time.Millisecond)
+
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, maxPacketSize)
+	n, _, err := l.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test.timing:1:3|ms\ntest.sub.timing:2|ms"
+	if p := string(b[:n]); p != expected {
+		t.Fatalf("expected %s, got %s", expected, p)
+	}
+
+	// close sends the rest
+	c.Histogram("size", 1)
+	c.Close()
+
+	n, _, err = l.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.size:1|h"
+	if p := string(b[:n]); p != expected {
+		t.Fatalf("expected %s, got %s", expected, p)
+	}
+}
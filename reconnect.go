@@ -0,0 +1,267 @@
+package statsd
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ConnState is the state of a RemoteClient's connection to the server.
+type ConnState int
+
+const (
+	// StateConnected is when stats are being sent to the server.
+	StateConnected ConnState = iota
+
+	// StateReconnecting is after connecting failed, until a retry succeeds.
+	StateReconnecting
+
+	// StateClosed is after the client was closed, it will not reconnect.
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	}
+
+	return "unknown"
+}
+
+// StateChange is sent to subscribers when the connection's state changes.
+type StateChange struct {
+	State ConnState
+
+	// Err is why connecting failed when reconnecting, and nil otherwise.
+	Err error
+}
+
+// stateChangeBuffer is how many state changes a subscriber can fall behind
+// before changes are dropped.
+const stateChangeBuffer = 16
+
+// minRetryDelay is the delay that failed attempts back off from when the
+// ReconnectDelay is zero, so the background retries do not spin.
+const minRetryDelay = 50 * time.Millisecond
+
+// errReconnectDelay is returned by connect while waiting to retry.
+var errReconnectDelay = errors.New("reconnect delay in progress")
+
+// reconnector limits how often the connection is reopened, backing off
+// exponentially with jitter while connecting keeps failing, and retries
+// in the background. The zero value is ready to use.
+type reconnector struct {
+	mutex sync.Mutex
+
+	state    ConnState
+	lastErr  error
+	maxDelay time.Duration
+
+	// connecting is true while an attempt is in progress, and no
+	// other attempt is allowed until next.
+	connecting bool
+	attempts   int
+	next       time.Time
+	timer      *time.Timer
+
+	subscribers []chan StateChange
+}
+
+// WithMaxReconnectDelay sets the most the delay between reconnect attempts
+// backs off to, doubling from the ReconnectDelay after each failure.
+// The default is DefaultMaxReconnectDelay.
+func WithMaxReconnectDelay(delay time.Duration) Option {
+	return func(client *RemoteClient) {
+		client.reconnector.maxDelay = delay
+	}
+}
+
+// State returns the state of the connection to the server.
+func (client *RemoteClient) State() ConnState {
+	r := &client.reconnector
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state
+}
+
+// LastError returns the error from the last failed attempt to connect,
+// or nil if the client has not failed to connect since it last connected.
+func (client *RemoteClient) LastError() error {
+	r := &client.reconnector
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.lastErr
+}
+
+// Subscribe returns a channel receiving the connection's state changes, and a
+// function to stop receiving them. Changes are dropped if the channel is full,
+// State always returns the latest. The channel is closed when unsubscribing or
+// when the client is closed.
+func (client *RemoteClient) Subscribe() (<-chan StateChange, func()) {
+	r := &client.reconnector
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ch := make(chan StateChange, stateChangeBuffer)
+	if r.state == StateClosed {
+		ch <- StateChange{State: StateClosed}
+		close(ch)
+		return ch, func() {}
+	}
+
+	r.subscribers = append(r.subscribers, ch)
+
+	unsubscribe := func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		for i, s := range r.subscribers {
+			if s == ch {
+				r.subscribers = append(r.subscribers[:i], r.subscribers[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// beginConnect starts an attempt to connect, returning an error if the client
+// is closed or another attempt is in progress or was too recent. If too recent,
+// the attempt is made in the background once the delay is over.
+func (client *RemoteClient) beginConnect() error {
+	r := &client.reconnector
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.state == StateClosed {
+		return ErrConnectionClosed
+	}
+
+	if r.connecting {
+		return errReconnectDelay
+	}
+
+	if delay := time.Until(r.next); delay > 0 {
+		if r.timer == nil {
+			r.timer = time.AfterFunc(delay, func() { client.connect() })
+		}
+
+		r.setState(StateReconnecting, r.lastErr)
+		return errReconnectDelay
+	}
+
+	r.connecting = true
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+
+	return nil
+}
+
+// reconnected ends the attempt to connect. After a failure the next attempt
+// is scheduled after the backoff delay.
+func (client *RemoteClient) reconnected(err error) {
+	r := &client.reconnector
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.connecting = false
+	if r.state == StateClosed {
+		return
+	}
+
+	if err == nil {
+		r.attempts = 0
+		r.next = time.Now().Add(client.ReconnectDelay)
+		r.lastErr = nil
+		r.setState(StateConnected, nil)
+
+		return
+	}
+
+	r.attempts++
+	delay := r.backoff(client.ReconnectDelay)
+	r.next = time.Now().Add(delay)
+	r.timer = time.AfterFunc(delay, func() { client.connect() })
+	r.lastErr = err
+	r.setState(StateReconnecting, err)
+}
+
+// backoff returns the delay before the next attempt, doubling the base delay,
+// or minRetryDelay if there is none, for each failed attempt up to the maximum
+// delay. The delay is between half and all of that, so clients that failed
+// together do not retry together. Attempts stop counting at the maximum.
+func (r *reconnector) backoff(base time.Duration) time.Duration {
+	if base <= 0 {
+		base = minRetryDelay
+	}
+
+	max := r.maxDelay
+	if max <= 0 {
+		max = DefaultMaxReconnectDelay
+	}
+
+	if base > max {
+		max = base
+	}
+
+	delay := base
+	i := 1
+	for ; i < r.attempts && delay < max; i++ {
+		delay *= 2
+	}
+	r.attempts = i
+
+	if delay > max {
+		delay = max
+	}
+
+	randLock.Lock() // rand objects are not thread safe.
+	jitter := time.Duration(randSource.Int63n(int64(delay/2) + 1))
+	randLock.Unlock()
+
+	return delay - delay/2 + jitter
+}
+
+// setState notifies the subscribers if the state changed.
+// Must be called with the mutex held.
+func (r *reconnector) setState(state ConnState, err error) {
+	if r.state == state {
+		return
+	}
+
+	r.state = state
+	for _, ch := range r.subscribers {
+		select {
+		case ch <- StateChange{State: state, Err: err}:
+		default:
+		}
+	}
+}
+
+// close stops any pending reconnect and notifies the subscribers, closing their channels.
+func (r *reconnector) close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+
+	r.setState(StateClosed, nil)
+	for _, ch := range r.subscribers {
+		close(ch)
+	}
+	r.subscribers = nil
+}
@@ -0,0 +1,177 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconnectorBackoff(t *testing.T) {
+	r := &reconnector{maxDelay: time.Second}
+
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, e := range expected {
+		r.attempts = i + 1
+		e *= time.Millisecond
+
+		for j := 0; j < 100; j++ {
+			if d := r.backoff(100 * time.Millisecond); d < e/2 || d > e {
+				t.Fatalf("attempt %d: expected between %v and %v, got %v", r.attempts, e/2, e, d)
+			}
+		}
+	}
+
+	// attempts stop counting at the maximum delay
+	r.attempts = 1000
+	r.backoff(100 * time.Millisecond)
+	if r.attempts != 5 {
+		t.Errorf("expected 5 attempts, got %d", r.attempts)
+	}
+
+	// no delay still waits before retrying
+	r.attempts = 1
+	if d := r.backoff(0); d < minRetryDelay/2 || d > minRetryDelay {
+		t.Errorf("expected between %v and %v, got %v", minRetryDelay/2, minRetryDelay, d)
+	}
+
+	// the default maximum, but never less than the base delay
+	r.maxDelay = 0
+	r.attempts = 100
+	if d := r.backoff(time.Second); d < DefaultMaxReconnectDelay/2 || d > DefaultMaxReconnectDelay {
+		t.Errorf("expected at most %v, got %v", DefaultMaxReconnectDelay, d)
+	}
+
+	if d := r.backoff(time.Hour); d < time.Hour/2 || d > time.Hour {
+		t.Errorf("expected at most %v, got %v", time.Hour, d)
+	}
+}
+
+func TestConnStateString(t *testing.T) {
+	cases := []struct {
+		state    ConnState
+		expected string
+	}{
+		{StateConnected, "connected"},
+		{StateReconnecting, "reconnecting"},
+		{StateClosed, "closed"},
+		{ConnState(10), "unknown"},
+	}
+
+	for _, tc := range cases {
+		if s := tc.state.String(); s != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, s)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	address := unusedAddress(t, "tcp")
+
+	c, err := NewWithOptions("tcp://"+address, WithLazyConnect(), WithReconnectDelay(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	changes, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+
+	if s := c.State(); s != StateReconnecting {
+		t.Fatalf("expected reconnecting, got %v", s)
+	}
+
+	if c.LastError() == nil {
+		t.Fatal("should have the connect error")
+	}
+
+	// the server starts
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("can not listen on the address again: %v", err)
+	}
+	defer l.Close()
+
+	select {
+	case change := <-changes:
+		if change.State != StateConnected || change.Err != nil {
+			t.Fatalf("expected connected, got %v %v", change.State, change.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("should have reconnected")
+	}
+
+	if err := c.LastError(); err != nil {
+		t.Fatalf("should have cleared the error, got %v", err)
+	}
+
+	// closing stops reconnecting and closes the channel
+	c.Close()
+
+	change := <-changes
+	if change.State != StateClosed {
+		t.Fatalf("expected closed, got %v", change.State)
+	}
+
+	if _, ok := <-changes; ok {
+		t.Fatal("should have closed the channel")
+	}
+
+	if c.reconnector.timer != nil {
+		t.Fatal("should have stopped the pending reconnect")
+	}
+
+	// subscribing to a closed client
+	changes, _ = c.Subscribe()
+	if change := <-changes; change.State != StateClosed {
+		t.Fatalf("expected closed, got %v", change.State)
+	}
+}
+
+func TestCloseStopsReconnect(t *testing.T) {
+	c, err := NewWithOptions("tcp://"+unusedAddress(t, "tcp"), WithLazyConnect(), WithReconnectDelay(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.reconnector.mutex.Lock()
+	pending := c.reconnector.timer != nil
+	c.reconnector.mutex.Unlock()
+
+	if !pending {
+		t.Fatal("should have scheduled a reconnect")
+	}
+
+	c.Close()
+
+	if c.reconnector.timer != nil {
+		t.Fatal("should have stopped the pending reconnect")
+	}
+
+	if s := c.State(); s != StateClosed {
+		t.Fatalf("expected closed, got %v", s)
+	}
+}
+
+func TestReconnectWithoutDelay(t *testing.T) {
+	var dials int32
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return nil, errors.New("unreachable")
+	}
+
+	c, err := NewWithOptions("tcp://127.0.0.1:8125", WithLazyConnect(), WithDialFunc(dial), WithReconnectDelay(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	c.Close()
+
+	// failed attempts back off from minRetryDelay rather than retrying at once
+	if n := atomic.LoadInt32(&dials); n < 2 || n > 10 {
+		t.Fatalf("expected a few retries, got %d dials", n)
+	}
+}
@@ -0,0 +1,97 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithResolveInterval re-resolves the server's host name every interval and, if
+// the current connection's IP is no longer one of the results, connects to the new
+// address. A dial resolves the host name only once, so without this the client
+// keeps sending to the old IP forever when a service moves, as UDP writes never fail.
+func WithResolveInterval(interval time.Duration) Option {
+	return func(client *RemoteClient) {
+		client.resolveInterval = interval
+	}
+}
+
+// resolve re-resolves the active address every resolveInterval until the connection is closed.
+func (client *RemoteClient) resolve() {
+	lookupHost := client.lookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+
+	ticker := time.NewTicker(client.resolveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-client.done:
+			return
+		}
+
+		address := client.ActiveAddress()
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), client.resolveInterval)
+		ips, err := lookupHost(ctx, host)
+		cancel()
+
+		if err != nil || len(ips) == 0 || client.connectedTo(ips) {
+			continue
+		}
+
+		conn, err := client.dial(net.JoinHostPort(ips[0], port), host)
+		if err != nil {
+			continue
+		}
+
+		client.swap(address, conn)
+	}
+}
+
+// connectedTo returns true if the current connection is to one of the ips.
+func (client *RemoteClient) connectedTo(ips []string) bool {
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	t, ok := client.transport.(*netTransport)
+	if !ok {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(t.conn.RemoteAddr().String())
+	if err != nil {
+		return false
+	}
+
+	remote := net.ParseIP(host)
+	for _, ip := range ips {
+		if remote.Equal(net.ParseIP(ip)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// swap replaces the current connection with conn, unless the client was closed
+// or the active address is no longer the one conn was resolved from.
+func (client *RemoteClient) swap(address string, conn net.Conn) {
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	if client.transport == nil || client.address != address {
+		conn.Close()
+		return
+	}
+
+	client.transport.Flush()
+	client.setConn(conn)
+}
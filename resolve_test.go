@@ -0,0 +1,84 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithResolveInterval(t *testing.T) {
+	l1, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+
+	_, port, _ := net.SplitHostPort(l1.LocalAddr().String())
+	l2, err := net.ListenPacket("udp", "127.0.0.2:"+port)
+	if err != nil {
+		t.Skipf("can not listen on a second loopback address: %v", err)
+	}
+	defer l2.Close()
+
+	var mutex sync.Mutex
+	resolved := "127.0.0.1"
+	lookupHost := func(ctx context.Context, host string) ([]string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if host != "127.0.0.1" {
+			t.Errorf("incorrect host, got %s", host)
+		}
+
+		return []string{resolved}, nil
+	}
+
+	c, err := NewWithOptions(l1.LocalAddr().String(),
+		WithResolveInterval(5*time.Millisecond),
+		func(client *RemoteClient) { client.lookupHost = lookupHost },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// same address, keeps the connection
+	time.Sleep(20 * time.Millisecond)
+	c.Count("first")
+
+	b := make([]byte, 1024)
+	l1.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := l1.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p, e := string(b[:n]), "first:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
+	}
+
+	// the address changed
+	mutex.Lock()
+	resolved = "127.0.0.2"
+	mutex.Unlock()
+
+	for i := 0; i < 100; i++ {
+		c.Count("second")
+
+		l2.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+		n, _, err = l2.ReadFrom(b)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		t.Fatal("should have switched to the new address")
+	}
+
+	if p, e := string(b[:n]), "second:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
+	}
+}
@@ -0,0 +1,112 @@
+package statsd
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrServiceCheckName is returned when sending a service check without a name.
+var ErrServiceCheckName = errors.New("service check name is required")
+
+// ServiceCheckStatus is the status of a DogStatsD service check.
+type ServiceCheckStatus int
+
+// Service check statuses.
+const (
+	StatusOK       ServiceCheckStatus = 0
+	StatusWarning  ServiceCheckStatus = 1
+	StatusCritical ServiceCheckStatus = 2
+	StatusUnknown  ServiceCheckStatus = 3
+)
+
+// ServiceCheckOptions are the optional fields of a service check,
+// empty fields are not sent.
+type ServiceCheckOptions struct {
+	// Timestamp is when the check ran, the server uses the arrival time if zero.
+	Timestamp time.Time
+	Hostname  string
+	Message   string
+
+	// Tags are sent after the client's Tags.
+	Tags []string
+}
+
+// ServiceCheck reports the status of a service check using the statsd.DefaultClient client.
+func ServiceCheck(name string, status ServiceCheckStatus, options *ServiceCheckOptions) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.ServiceCheck(name, status, options)
+}
+
+// ServiceCheck reports the status of a service check, such as the health
+// of a downstream dependency, using the DogStatsD format,
+// _sc|name|status|d:timestamp|h:hostname|#tags|m:message
+// Options may be nil. Service checks are not prefixed or sampled,
+// but include the client's Tags.
+func (client *RemoteClient) ServiceCheck(name string, status ServiceCheckStatus, options *ServiceCheckOptions) error {
+	if name == "" {
+		return ErrServiceCheckName
+	}
+
+	if options == nil {
+		options = &ServiceCheckOptions{}
+	}
+
+	message := make([]byte, 0, len(name)+len(options.Message)+64)
+	message = append(message, "_sc|"...)
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '|', '\n':
+			message = append(message, '_')
+		default:
+			message = append(message, c)
+		}
+	}
+
+	message = append(message, '|')
+	message = strconv.AppendInt(message, int64(status), 10)
+
+	if !options.Timestamp.IsZero() {
+		message = append(message, "|d:"...)
+		message = strconv.AppendInt(message, options.Timestamp.Unix(), 10)
+	}
+
+	message = appendEventField(message, "|h:", options.Hostname)
+
+	tags := client.Tags
+	if len(options.Tags) != 0 {
+		tags = make([]string, 0, len(client.Tags)+len(options.Tags))
+		tags = append(tags, client.Tags...)
+		tags = append(tags, options.Tags...)
+	}
+
+	message = appendTags(message, tags)
+
+	// the message must be the last field. New lines are escaped
+	// and "m:" is escaped so it can not be read as another message.
+	if m := options.Message; m != "" {
+		message = append(message, "|m:"...)
+		for i := 0; i < len(m); i++ {
+			switch {
+			case m[i] == '\n':
+				message = append(message, '\\', 'n')
+			case m[i] == 'm' && i+1 < len(m) && m[i+1] == ':':
+				message = append(message, 'm', '\\', ':')
+				i++
+			default:
+				message = append(message, m[i])
+			}
+		}
+	}
+
+	return client.write(message)
+}
+
+// ServiceCheck on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) ServiceCheck(name string, status ServiceCheckStatus, options *ServiceCheckOptions) error {
+	return nil
+}
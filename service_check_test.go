@@ -0,0 +1,74 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceCheck(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+	DefaultClient = c
+
+	err := ServiceCheck("redis.can_connect", StatusCritical, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "_sc|redis.can_connect|2"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	DefaultClient = nil
+	ServiceCheck("redis.can_connect", StatusOK, nil) // should not panic
+}
+
+func TestClientServiceCheck(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+
+	err := c.ServiceCheck("redis.can_connect", StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "_sc|redis.can_connect|0"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// all the fields
+	buf.Reset()
+	c.Tags = []string{"env:prod"}
+	err = c.ServiceCheck("db|check", StatusWarning, &ServiceCheckOptions{
+		Timestamp: time.Unix(1400000000, 0),
+		Hostname:  "db1",
+		Message:   "slow\nreplica m:lag",
+		Tags:      []string{"role:primary"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `_sc|db_check|1|d:1400000000|h:db1|#env:prod,role:primary|m:slow\nreplica m\:lag`
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// name required
+	buf.Reset()
+	err = c.ServiceCheck("", StatusUnknown, nil)
+	if err != ErrServiceCheckName {
+		t.Fatalf("expected ErrServiceCheckName, got %v", err)
+	}
+
+	if b := buf.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+}
+
+func TestNoopClientServiceCheck(t *testing.T) {
+	var s Stater = NoopClient{}
+	if err := s.ServiceCheck("check", StatusOK, nil); err != nil {
+		t.Fatal(err)
+	}
+}
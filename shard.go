@@ -0,0 +1,258 @@
+package statsd
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// shardReplicas is the number of points each server has on the hash ring.
+// More points spread the stats more evenly between the servers.
+const shardReplicas = 160
+
+// ShardedClient implements Stater by sending each stat to one of several servers,
+// picked by hashing the prefixed stat name onto a consistent hash ring. Every value
+// of a stat is aggregated by the same server, and adding or removing a server only
+// moves the stats of about 1/n of the ring.
+type ShardedClient struct {
+	shards []*RemoteClient
+	ring   *hashRing
+}
+
+// NewSharded creates a RemoteClient for each address, with the same options, and
+// returns a ShardedClient that spreads the stats between them. Servers are placed
+// on the ring by address, so the order of the addresses does not matter.
+func NewSharded(addresses []string, options ...Option) (*ShardedClient, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("no addresses to shard between")
+	}
+
+	client := &ShardedClient{ring: newHashRing(addresses)}
+	for _, address := range addresses {
+		shard, err := NewWithOptions(address, options...)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+
+		client.shards = append(client.shards, shard)
+	}
+
+	return client, nil
+}
+
+// shard returns the client for the stat, hashing it with the prefix.
+func (client *ShardedClient) shard(stat string) *RemoteClient {
+	prefix := client.shards[0].prefix
+
+	h := uint32(fnvOffset)
+	if len(prefix) != 0 {
+		for _, c := range prefix {
+			h = fnvAdd(h, c)
+		}
+		h = fnvAdd(h, '.')
+	}
+
+	for i := 0; i < len(stat); i++ {
+		h = fnvAdd(h, stat[i])
+	}
+
+	return client.shards[client.ring.get(h)]
+}
+
+// Count adds 1 to the provided stat on the stat's server.
+func (client *ShardedClient) Count(stat string, rate ...float32) error {
+	return client.shard(stat).Count(stat, rate...)
+}
+
+// CountMultiple adds `count` to the provided stat on the stat's server.
+func (client *ShardedClient) CountMultiple(stat string, count int, rate ...float32) error {
+	return client.shard(stat).CountMultiple(stat, count, rate...)
+}
+
+// Measure reports a duration to the provided stat on the stat's server.
+func (client *ShardedClient) Measure(stat string, delta time.Duration, rate ...float32) error {
+	return client.shard(stat).Measure(stat, delta, rate...)
+}
+
+// Histogram reports an arbitrary value to the provided stat on the stat's server.
+func (client *ShardedClient) Histogram(stat string, value float64, rate ...float32) error {
+	return client.shard(stat).Histogram(stat, value, rate...)
+}
+
+// Distribution reports an arbitrary value to the provided stat on the stat's server.
+func (client *ShardedClient) Distribution(stat string, value float64, rate ...float32) error {
+	return client.shard(stat).Distribution(stat, value, rate...)
+}
+
+// Gauge sets the gauge on the stat's server.
+func (client *ShardedClient) Gauge(stat string, value interface{}) error {
+	return client.shard(stat).Gauge(stat, value)
+}
+
+// GaugeInt64 sets the gauge on the stat's server.
+func (client *ShardedClient) GaugeInt64(stat string, value int64) error {
+	return client.shard(stat).GaugeInt64(stat, value)
+}
+
+// GaugeUint64 sets the gauge on the stat's server.
+func (client *ShardedClient) GaugeUint64(stat string, value uint64) error {
+	return client.shard(stat).GaugeUint64(stat, value)
+}
+
+// GaugeFloat64 sets the gauge on the stat's server.
+func (client *ShardedClient) GaugeFloat64(stat string, value float64) error {
+	return client.shard(stat).GaugeFloat64(stat, value)
+}
+
+// GaugeAbsolute sets the gauge on the stat's server.
+func (client *ShardedClient) GaugeAbsolute(stat string, value float64) error {
+	return client.shard(stat).GaugeAbsolute(stat, value)
+}
+
+// GaugeDelta changes the gauge on the stat's server.
+func (client *ShardedClient) GaugeDelta(stat string, delta float64) error {
+	return client.shard(stat).GaugeDelta(stat, delta)
+}
+
+// Set adds the value to the set on the stat's server.
+func (client *ShardedClient) Set(stat string, value string) error {
+	return client.shard(stat).Set(stat, value)
+}
+
+// Event sends the event to the server picked by hashing its title.
+func (client *ShardedClient) Event(e *Event) error {
+	return client.shard(e.Title).Event(e)
+}
+
+// ServiceCheck sends the service check to the server picked by hashing its name.
+func (client *ShardedClient) ServiceCheck(name string, status ServiceCheckStatus, options *ServiceCheckOptions) error {
+	return client.shard(name).ServiceCheck(name, status, options)
+}
+
+// Substater returns another ShardedClient using the same connections
+// with the extra prefix, see RemoteClient.Substater.
+func (client *ShardedClient) Substater(extraPrefix ...string) Stater {
+	newClient := &ShardedClient{ring: client.ring}
+	for _, shard := range client.shards {
+		newClient.shards = append(newClient.shards, shard.Substater(extraPrefix...).(*RemoteClient))
+	}
+
+	return newClient
+}
+
+// WithTags returns another ShardedClient using the same connections
+// that adds the tags to every stat, see RemoteClient.WithTags.
+// Tags are not hashed, so a stat goes to the same server whatever its tags.
+func (client *ShardedClient) WithTags(tags ...string) Stater {
+	newClient := &ShardedClient{ring: client.ring}
+	for _, shard := range client.shards {
+		newClient.shards = append(newClient.shards, shard.WithTags(tags...).(*RemoteClient))
+	}
+
+	return newClient
+}
+
+// SetDefaultRate sets the default rate on all the servers' clients.
+func (client *ShardedClient) SetDefaultRate(rate float32) {
+	for _, shard := range client.shards {
+		shard.SetDefaultRate(rate)
+	}
+}
+
+// Close closes the connections to all the servers,
+// returning the first error.
+func (client *ShardedClient) Close() error {
+	var err error
+	for _, shard := range client.shards {
+		if e := shard.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// hashRing maps hashes to servers, each server owns the hashes
+// up to each of its points on the ring.
+type hashRing struct {
+	points []uint32
+	shards []int // shard index for each point
+}
+
+func newHashRing(addresses []string) *hashRing {
+	type point struct {
+		hash  uint32
+		shard int
+	}
+
+	points := make([]point, 0, len(addresses)*shardReplicas)
+	for i, address := range addresses {
+		key := make([]byte, 0, len(address)+8)
+		for r := 0; r < shardReplicas; r++ {
+			key = append(key[:0], address...)
+			key = append(key, '-')
+			key = strconv.AppendInt(key, int64(r), 10)
+
+			h := uint32(fnvOffset)
+			for _, c := range key {
+				h = fnvAdd(h, c)
+			}
+
+			points = append(points, point{hash: mix(h), shard: i})
+		}
+	}
+
+	// ties are broken by address so the ring does not depend on the order.
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return addresses[points[i].shard] < addresses[points[j].shard]
+		}
+		return points[i].hash < points[j].hash
+	})
+
+	ring := &hashRing{
+		points: make([]uint32, len(points)),
+		shards: make([]int, len(points)),
+	}
+	for i, p := range points {
+		ring.points[i] = p.hash
+		ring.shards[i] = p.shard
+	}
+
+	return ring
+}
+
+// get returns the index of the shard owning the FNV-1a hash.
+func (ring *hashRing) get(h uint32) int {
+	h = mix(h)
+	i := sort.Search(len(ring.points), func(i int) bool { return ring.points[i] >= h })
+	if i == len(ring.points) {
+		i = 0
+	}
+
+	return ring.shards[i]
+}
+
+// FNV-1a is used, rather than hash/fnv, so hashing a stat does not allocate.
+const (
+	fnvOffset = 2166136261
+	fnvPrime  = 16777619
+)
+
+func fnvAdd(h uint32, c byte) uint32 {
+	return (h ^ uint32(c)) * fnvPrime
+}
+
+// mix spreads the bits of the hash, the finalizer from MurmurHash3,
+// as FNV-1a of similar keys are close together on the ring.
+func mix(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return h
+}
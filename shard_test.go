@@ -0,0 +1,137 @@
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashRing(t *testing.T) {
+	addresses := []string{"10.0.0.1:8125", "10.0.0.2:8125", "10.0.0.3:8125"}
+	ring := newHashRing(addresses)
+
+	// the order of the addresses does not matter
+	reversed := newHashRing([]string{addresses[2], addresses[1], addresses[0]})
+
+	hash := func(key string) uint32 {
+		h := uint32(fnvOffset)
+		for i := 0; i < len(key); i++ {
+			h = fnvAdd(h, key[i])
+		}
+		return h
+	}
+
+	counts := make([]int, len(addresses))
+	for i := 0; i < 10000; i++ {
+		h := hash("stat." + strconv.Itoa(i))
+		shard := ring.get(h)
+		counts[shard]++
+
+		if a, e := addresses[2-reversed.get(h)], addresses[shard]; a != e {
+			t.Fatalf("expected %s, got %s", e, a)
+		}
+	}
+
+	for i, count := range counts {
+		if count < 2000 {
+			t.Errorf("%s got too few stats, %d", addresses[i], count)
+		}
+	}
+
+	// adding a server only moves stats to it
+	grown := newHashRing(append(addresses, "10.0.0.4:8125"))
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		h := hash("stat." + strconv.Itoa(i))
+		shard := grown.get(h)
+		if shard == ring.get(h) {
+			continue
+		}
+
+		if shard != 3 {
+			t.Fatalf("should only move stats to the new server, moved to %s", addresses[shard])
+		}
+		moved++
+	}
+
+	if moved < 1500 || moved > 3500 {
+		t.Errorf("expected about a quarter of the stats to move, got %d", moved)
+	}
+}
+
+func TestNewSharded(t *testing.T) {
+	// This will not compile if ShardedClient does not implement the Stater interface.
+	var _ Stater = &ShardedClient{}
+
+	var listeners []net.PacketConn
+	var addresses []string
+	for i := 0; i < 2; i++ {
+		l, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		listeners = append(listeners, l)
+		addresses = append(addresses, l.LocalAddr().String())
+	}
+
+	c, err := NewSharded(addresses, WithPrefix("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 20; i++ {
+		c.Count("count" + strconv.Itoa(i))
+		c.Count("count" + strconv.Itoa(i))
+	}
+
+	// each stat is always sent to the same server
+	servers := make(map[string]string)
+	b := make([]byte, 1024)
+	for _, l := range listeners {
+		received := 0
+		for {
+			l.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			n, _, err := l.ReadFrom(b)
+			if err != nil {
+				break
+			}
+			received++
+
+			stat := strings.TrimSuffix(string(b[:n]), ":1|c")
+			if s, ok := servers[stat]; ok && s != l.LocalAddr().String() {
+				t.Fatalf("%s was sent to %s and %s", stat, s, l.LocalAddr())
+			}
+			servers[stat] = l.LocalAddr().String()
+		}
+
+		if received == 0 {
+			t.Errorf("%s should have received stats", l.LocalAddr())
+		}
+	}
+
+	if len(servers) != 20 {
+		t.Errorf("expected 20 stats, got %v", servers)
+	}
+
+	// the prefix is hashed with the stat
+	sub := c.Substater("sub").(*ShardedClient)
+	for i := 0; i < 20; i++ {
+		stat := "count" + strconv.Itoa(i)
+		if a, e := sub.shard(stat).address, c.shard("sub."+stat).address; a != e {
+			t.Fatalf("expected %s, got %s", e, a)
+		}
+	}
+}
+
+func TestNewShardedNoAddresses(t *testing.T) {
+	_, err := NewSharded(nil)
+	if err == nil {
+		t.Fatal("should require an address")
+	}
+}
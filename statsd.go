@@ -3,17 +3,22 @@
 package statsd
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"net"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// DefaultClient is used by package functions statsd.Count, statsd.Measure, statsd.Gauge functions.
+// DefaultClient is used by package functions statsd.Count, statsd.Measure, statsd.Gauge, statsd.Set functions.
 var DefaultClient Stater = NoopClient{}
 
 // DefaultRate is the rate used for Measure and Count calls if none is provided.
@@ -22,6 +27,12 @@ var DefaultRate float32 = 1.0
 // DefaultReconnectDelay is the time before trying, yet again, to reconnect after a network error.
 var DefaultReconnectDelay = time.Second
 
+// DefaultDialTimeout is the most time spent connecting to the server.
+var DefaultDialTimeout = 5 * time.Second
+
+// DefaultMaxReconnectDelay is the most the reconnect delay backs off to after repeated failures.
+var DefaultMaxReconnectDelay = 30 * time.Second
+
 var (
 	// ErrConnectionClosed is triggered when trying to send on a closed connection.
 	// ie. you closed the Client and then tried to send again
@@ -29,8 +40,31 @@ var (
 
 	// ErrConnectionWrite is returned if there was a problem sending the request.
 	ErrConnectionWrite = errors.New("wrote no bytes")
+
+	// ErrSocketBufferFull is returned when the server is not reading stats as fast
+	// as they are sent and the socket's buffer is full, so the stat was dropped.
+	ErrSocketBufferFull = errors.New("socket buffer full")
+
+	// ErrNotConnected is returned, and the stat dropped, when a client created
+	// with WithLazyConnect has not been able to connect to the server yet.
+	ErrNotConnected = errors.New("not connected yet")
 )
 
+// unixgramWriteTimeout is how long to wait for room in a full unixgram socket
+// buffer before dropping the stat, rather than blocking until the server catches up.
+const unixgramWriteTimeout = 100 * time.Millisecond
+
+// InvalidValueError is returned when a value can not be represented
+// in the StatsD protocol, such as NaN, infinity or an unsupported type.
+type InvalidValueError struct {
+	Stat  string
+	Value interface{}
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("statsd: invalid value %#v for stat %s", e.Value, e.Stat)
+}
+
 // randSource is the random source for statsd rate limiting/throttling.
 // It is initialized at startup using the current nanoseconds as the seed.
 var (
@@ -49,9 +83,20 @@ type Stater interface {
 	Count(stat string, rate ...float32) error
 	CountMultiple(stat string, count int, rate ...float32) error
 	Measure(stat string, delta time.Duration, rate ...float32) error
+	Histogram(stat string, value float64, rate ...float32) error
+	Distribution(stat string, value float64, rate ...float32) error
 	Gauge(stat string, value interface{}) error
+	GaugeInt64(stat string, value int64) error
+	GaugeUint64(stat string, value uint64) error
+	GaugeFloat64(stat string, value float64) error
+	GaugeAbsolute(stat string, value float64) error
+	GaugeDelta(stat string, delta float64) error
+	Set(stat string, value string) error
+	Event(e *Event) error
+	ServiceCheck(name string, status ServiceCheckStatus, options *ServiceCheckOptions) error
 
 	Substater(extraPrefix ...string) Stater
+	WithTags(tags ...string) Stater
 	SetDefaultRate(rate float32)
 
 	Close() error
@@ -65,44 +110,243 @@ type NoopClient struct{}
 type RemoteClient struct {
 	ReconnectDelay time.Duration
 	DefaultRate    float32
-	prefix         []byte
+
+	// Tags are DogStatsD tags, "key:value" or "key", sent with every stat
+	// from this client. Tags added with WithTags are appended to these.
+	Tags []string
+
+	// Precision is how Measure reports durations, the zero value
+	// is whole milliseconds.
+	Precision Precision
+
+	prefix    []byte
+	timestamp time.Time
+
+	// sampled is set on the children of a FanOutClient, which has already
+	// sampled the stats, so they are sent with their rate but not sampled again.
+	sampled bool
+
 	*connection
 }
 
+// Precision is how Measure reports durations.
+type Precision struct {
+	metricType string
+	decimals   int
+}
+
+var (
+	// Milliseconds reports whole milliseconds, truncating the rest. This is the default.
+	Milliseconds = Precision{metricType: "ms"}
+
+	// Microseconds reports whole microseconds using the |us type with a
+	// MicrosecondsEncoder, for servers that support it. The StatsD dialects do
+	// not support |us, so otherwise milliseconds with 3 decimals are reported.
+	Microseconds = Precision{metricType: "us"}
+)
+
+// FractionalMilliseconds reports milliseconds with up to decimals
+// digits after the decimal point, eg. 2.8 for 2800 microseconds.
+func FractionalMilliseconds(decimals int) Precision {
+	return Precision{metricType: "ms", decimals: decimals}
+}
+
 type connection struct {
-	address       string
-	buf           *bufio.ReadWriter // need to read for tests
-	conn          net.Conn
-	reconnectChan chan struct{}
-	writeMutex    sync.Mutex
+	network      string
+	address      string
+	tlsConfig    *tls.Config
+	encoder      Encoder
+	containerID  string
+	packer       *packer
+	packInterval time.Duration
+
+	resolveInterval time.Duration
+	lookupHost      func(ctx context.Context, host string) ([]string, error)
+
+	// endpoints are the addresses to fail over to, in order, the first being
+	// the primary. address is the active one, and endpoints[active].
+	endpoints        []string
+	active           int
+	failbackInterval time.Duration
+
+	// lazy clients drop stats until connected, counting them in dropped.
+	lazy    bool
+	dropped atomic.Uint64
+
+	// writeTimeout is the deadline for each write to a dialed connection,
+	// and dialTimeout the deadline for dialing it.
+	writeTimeout time.Duration
+	dialTimeout  time.Duration
+
+	// openTransport and dialContext replace dialing the address with net.Dial.
+	openTransport func() (Transport, error)
+	dialContext   func(ctx context.Context, network, address string) (net.Conn, error)
+
+	transport   Transport
+	reconnector reconnector
+	writeMutex  writeLock
+
+	// done is closed when the client is closed, to stop any background goroutines.
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-// New opens a new UDP connection to the given server. The prefix
+// Option configures a RemoteClient created with NewWithOptions.
+type Option func(*RemoteClient)
+
+// WithPrefix sets the prefix that will be prepended to any stat using the client.
+func WithPrefix(prefix string) Option {
+	return func(client *RemoteClient) {
+		client.prefix = []byte(prefix)
+	}
+}
+
+// WithEncoder sets the Encoder used to format stats for the server,
+// the default is the DogStatsDEncoder.
+func WithEncoder(encoder Encoder) Option {
+	return func(client *RemoteClient) {
+		client.encoder = encoder
+	}
+}
+
+// New opens a new connection to the given server. The prefix
 // is optional and will be prepended to any stat using this client.
+// The address is host:port for UDP, or prefixed with the network,
+// such as tcp://host:port, unix:///var/run/statsd.sock for a stream socket
+// or unixgram:///var/run/statsd.sock for a datagram socket.
+// Over TCP and unix stream sockets each stat is sent on its own line.
 func New(address string, prefix ...string) (*RemoteClient, error) {
 	p := ""
 	if len(prefix) > 0 {
 		p = prefix[0]
 	}
 
+	return NewWithOptions(address, WithPrefix(p))
+}
+
+// WithTLS encrypts the connection with TLS using the config, which can set
+// client certificates, custom root CAs and the server name. TLS is always over
+// TCP, or a unix stream socket, so an address without a network uses TCP, while
+// an explicit udp:// or unixgram:// address returns an error. The server name
+// defaults to the host in the address. The handshake is part of connecting,
+// so it is limited by the dial timeout.
+func WithTLS(config *tls.Config) Option {
+	return func(client *RemoteClient) {
+		client.tlsConfig = config
+	}
+}
+
+// WithReconnectDelay sets the time before trying, yet again,
+// to reconnect after a network error. The delay doubles after each
+// failed attempt, up to the maximum set with WithMaxReconnectDelay.
+// With no delay, failed attempts are still retried after a short delay.
+func WithReconnectDelay(delay time.Duration) Option {
+	return func(client *RemoteClient) {
+		client.ReconnectDelay = delay
+	}
+}
+
+// WithDialTimeout sets the most time spent connecting to the server, including
+// any TLS handshake. The default is DefaultDialTimeout.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(client *RemoteClient) {
+		client.dialTimeout = timeout
+	}
+}
+
+// WithPrecision sets the precision Measure uses to report durations.
+func WithPrecision(precision Precision) Option {
+	return func(client *RemoteClient) {
+		client.Precision = precision
+	}
+}
+
+// NewWithOptions opens a new connection to the given server, using the same
+// addresses as New, and configures the client with the options.
+//
+//	client, err := statsd.NewWithOptions("statsd:8125",
+//		statsd.WithPrefix("gopher_service"),
+//		statsd.WithEncoder(statsd.InfluxDBEncoder{}),
+//	)
+func NewWithOptions(address string, options ...Option) (*RemoteClient, error) {
+	// only an address without a network is switched to TCP for TLS.
+	explicit := strings.Contains(address, "://")
+
+	network, address, err := splitNetwork(address)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &RemoteClient{
 		ReconnectDelay: DefaultReconnectDelay,
-		prefix:         []byte(p),
 		connection: &connection{
-			address:       address,
-			reconnectChan: make(chan struct{}, 1),
+			network: network,
+			address: address,
+			done:    make(chan struct{}),
 		},
 	}
-	client.reconnectChan <- struct{}{}
 
-	err := client.connect()
+	for _, option := range options {
+		option(client)
+	}
+
+	if client.tlsConfig != nil {
+		switch client.network {
+		case "udp", "udp4", "udp6":
+			if explicit {
+				return nil, fmt.Errorf("TLS is not supported over %s", client.network)
+			}
+			client.network = "tcp"
+		case "unixgram":
+			return nil, fmt.Errorf("TLS is not supported over unixgram")
+		}
+	}
+
+	err = client.setEndpoints()
 	if err != nil {
 		return nil, err
 	}
 
+	// a lazy client keeps retrying in the background.
+	err = client.connect()
+	if err != nil && !client.lazy {
+		client.Close()
+		return nil, err
+	}
+
+	if client.packer != nil && client.packInterval > 0 {
+		go client.flushPacked()
+	}
+
+	// custom transports do not dial the address.
+	if client.resolveInterval > 0 && !strings.HasPrefix(client.network, "unix") && client.openTransport == nil {
+		go client.resolve()
+	}
+
+	if len(client.endpoints) > 1 && client.failbackInterval > 0 && client.openTransport == nil {
+		go client.failback()
+	}
+
 	return client, nil
 }
 
+// splitNetwork returns the network and address from an address
+// like tcp://host:port, or udp if there is no network.
+func splitNetwork(address string) (string, string, error) {
+	i := strings.Index(address, "://")
+	if i < 0 {
+		return "udp", address, nil
+	}
+
+	network := address[:i]
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+		return network, address[i+3:], nil
+	}
+
+	return "", "", fmt.Errorf("unsupported network %s", network)
+}
+
 // Substater returns another RemoteClient using the same connection, optionally
 // allowing an extra prefix to be added. This can be used to have clients with the
 // same connection but with different sampling rates. A dot (.) will be added
@@ -111,6 +355,10 @@ func (client *RemoteClient) Substater(extraPrefix ...string) Stater {
 	newClient := &RemoteClient{
 		ReconnectDelay: client.ReconnectDelay,
 		DefaultRate:    client.DefaultRate,
+		Tags:           client.Tags,
+		Precision:      client.Precision,
+		timestamp:      client.timestamp,
+		sampled:        client.sampled,
 		connection:     client.connection,
 	}
 
@@ -135,6 +383,34 @@ func (client *RemoteClient) Substater(extraPrefix ...string) Stater {
 	return newClient
 }
 
+// WithTags returns another RemoteClient using the same connection and prefix
+// that adds the tags to every stat, after the client's own Tags.
+// Tags use the DogStatsD format, "key:value" or just "key", and the reserved
+// characters '|', ',' and '#' are replaced with '_'.
+//
+//	client.WithTags("route:/upload", "status:200").Count("requests")
+func (client *RemoteClient) WithTags(tags ...string) Stater {
+	newClient := *client
+	newClient.Tags = make([]string, 0, len(client.Tags)+len(tags))
+	newClient.Tags = append(newClient.Tags, client.Tags...)
+	newClient.Tags = append(newClient.Tags, tags...)
+
+	return &newClient
+}
+
+// WithTimestamp returns another RemoteClient using the same connection, prefix
+// and tags that sends the timestamp with every count and gauge, instead of the
+// server using the time they arrive. Useful when replaying buffered metrics.
+// Only the DogStatsDEncoder sends timestamps, other dialects ignore them.
+//
+//	client.WithTimestamp(batch.Time).CountMultiple("jobs", batch.Len())
+func (client *RemoteClient) WithTimestamp(timestamp time.Time) *RemoteClient {
+	newClient := *client
+	newClient.timestamp = timestamp
+
+	return &newClient
+}
+
 // SetDefaultRate sets the default rate for the stater.
 // As a function so it can be part of the Stater interface to make
 // set the default rate more flexible.
@@ -142,37 +418,143 @@ func (client *RemoteClient) SetDefaultRate(rate float32) {
 	client.DefaultRate = rate
 }
 
+// connect connects to the server, unless the client is closed or the reconnect
+// delay since the last attempt is in progress. Failed attempts are retried in the
+// background, backing off up to the connection's maximum reconnect delay.
 func (client *RemoteClient) connect() error {
-	// here we use client.reconnectChan as a nonblocking mutex.
-	select {
-	case <-client.reconnectChan:
-	default:
-		return fmt.Errorf("reconnect delay in progress")
+	err := client.beginConnect()
+	if err != nil {
+		return err
+	}
+
+	err = client.open()
+	client.reconnected(err)
+
+	return err
+}
+
+// open dials the active address, or opens a custom Transport,
+// and replaces the current transport.
+// Dialing is done without the writeMutex, so stats are not held up by a slow
+// server, and the lock is only taken to replace the transport.
+func (client *RemoteClient) open() error {
+	// do not reopen a closed client.
+	if client.closed() {
+		return ErrConnectionClosed
 	}
 
-	// release the reconnect lock after the delay
-	go func() {
-		time.Sleep(client.ReconnectDelay)
-		select {
-		case client.reconnectChan <- struct{}{}:
-		default:
+	if client.openTransport != nil {
+		transport, err := client.openTransport()
+		if err != nil {
+			return err
 		}
-	}()
 
-	client.writeMutex.Lock()
-	defer client.writeMutex.Unlock()
+		return client.replaceTransport(transport)
+	}
 
-	conn, err := net.Dial("udp", client.address)
+	if len(client.endpoints) > 1 {
+		return client.connectEndpoint()
+	}
+
+	address := client.ActiveAddress()
+	conn, err := client.dial(address, hostname(address))
 	if err != nil {
 		return err
 	}
 
-	client.conn = conn
-	client.buf = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return client.replaceTransport(newNetTransport(client.network, conn, client.writeTimeout))
+}
+
+// replaceTransport replaces the current transport, unless the client was
+// closed while it was being opened.
+func (client *RemoteClient) replaceTransport(transport Transport) error {
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	if client.closed() {
+		transport.Close()
+		return ErrConnectionClosed
+	}
+
+	client.setTransport(transport)
 
 	return nil
 }
 
+// closed returns true once the client has been closed.
+func (c *connection) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// dial opens a connection to the address, using TLS if configured,
+// giving up after the dial timeout.
+// The server is verified using the host, as the address may be a resolved IP.
+func (client *RemoteClient) dial(address string, host string) (net.Conn, error) {
+	dialContext := client.dialContext
+	if dialContext == nil {
+		dialContext = (&net.Dialer{}).DialContext
+	}
+
+	timeout := client.dialTimeout
+	if timeout <= 0 {
+		timeout = DefaultDialTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialContext(ctx, client.network, address)
+	if err != nil || client.tlsConfig == nil {
+		return conn, err
+	}
+
+	config := client.tlsConfig
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// hostname returns the host of a host:port address.
+func hostname(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	return host
+}
+
+// setConn replaces the transport with one over the connection, closing the current one.
+// Must be called with the writeMutex held.
+func (client *RemoteClient) setConn(conn net.Conn) {
+	client.setTransport(newNetTransport(client.network, conn, client.writeTimeout))
+}
+
+// setTransport replaces the transport, closing the current one.
+// Must be called with the writeMutex held.
+func (client *RemoteClient) setTransport(transport Transport) {
+	if client.transport != nil {
+		client.transport.Close()
+	}
+
+	client.transport = transport
+}
+
 // Count adds 1 to the provided stat using the statsd.DefaultClient.
 func Count(stat string, rate ...float32) error {
 	return CountMultiple(stat, 1, rate...)
@@ -199,6 +581,26 @@ func Measure(stat string, delta time.Duration, rate ...float32) error {
 	return client.Measure(stat, delta, rate...)
 }
 
+// Histogram reports a value to a histogram using the statsd.DefaultClient client.
+func Histogram(stat string, value float64, rate ...float32) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.Histogram(stat, value, rate...)
+}
+
+// Distribution reports a value to a distribution using the statsd.DefaultClient client.
+func Distribution(stat string, value float64, rate ...float32) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.Distribution(stat, value, rate...)
+}
+
 // Gauge set a StatsD gauge value using the statsd.DefaultClient client.
 func Gauge(stat string, value interface{}) error {
 	client := DefaultClient
@@ -209,6 +611,66 @@ func Gauge(stat string, value interface{}) error {
 	return client.Gauge(stat, value)
 }
 
+// GaugeInt64 sets a StatsD gauge value using the statsd.DefaultClient client.
+func GaugeInt64(stat string, value int64) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.GaugeInt64(stat, value)
+}
+
+// GaugeUint64 sets a StatsD gauge value using the statsd.DefaultClient client.
+func GaugeUint64(stat string, value uint64) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.GaugeUint64(stat, value)
+}
+
+// GaugeFloat64 sets a StatsD gauge value using the statsd.DefaultClient client.
+func GaugeFloat64(stat string, value float64) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.GaugeFloat64(stat, value)
+}
+
+// GaugeAbsolute sets a StatsD gauge to exactly value using the statsd.DefaultClient client.
+func GaugeAbsolute(stat string, value float64) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.GaugeAbsolute(stat, value)
+}
+
+// GaugeDelta changes a StatsD gauge by delta using the statsd.DefaultClient client.
+func GaugeDelta(stat string, delta float64) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.GaugeDelta(stat, delta)
+}
+
+// Set adds a value to a StatsD set using the statsd.DefaultClient client.
+func Set(stat string, value string) error {
+	client := DefaultClient
+	if client == nil {
+		client = NoopClient{}
+	}
+
+	return client.Set(stat, value)
+}
+
 // Count adds 1 to the provided stat. Rate is optional and
 // uses the client's DefaultRate if not provided, but if that's zero,
 // uses the global statsd.DefaultRate which is initially set as 1.0.
@@ -226,110 +688,475 @@ func (client *RemoteClient) Count(stat string, rate ...float32) error {
 // A rate value of 0.1 will only send one in every 10 calls to the
 // server. The statsd server will adjust its aggregation accordingly.
 func (client *RemoteClient) CountMultiple(stat string, count int, rate ...float32) error {
-	r := client.DefaultRate
-	if r == 0 {
-		r = DefaultRate
-	}
+	r := client.rate(rate)
 
-	if len(rate) > 0 {
-		r = rate[0]
-	}
-
-	// fmt.Sprintf("%d|c", count)
-	data := make([]byte, 0, 20)
+	// fmt.Sprintf("%d", count)
+	data := client.buffer(stat, 24)
 	data = strconv.AppendInt(data, int64(count), 10)
-	data = append(data, '|', 'c')
 
-	return client.submit(stat, data, r)
+	return client.submit(stat, data, "c", r)
 }
 
 // Measure reports a duration to the provided stat (plus the prefix).
 // Rate is optional and uses the client's DefaultRate if not provided, but if that's zero,
 // uses the global statsd.DefaultRate which is initially set as 1.0.
 // So, if you don't make any changes and the rate is not provided, 1.0 will be used.
+// The duration is reported with the client's Precision, whole milliseconds by default.
 func (client *RemoteClient) Measure(stat string, delta time.Duration, rate ...float32) error {
-	r := client.DefaultRate
-	if r == 0 {
-		r = DefaultRate
+	r := client.rate(rate)
+
+	data := client.buffer(stat, 24)
+	data, metricType := client.appendDuration(data, delta)
+
+	return client.submit(stat, data, metricType, r)
+}
+
+// appendDuration appends the duration using the client's Precision
+// and returns the metric type for it, ms or us.
+func (client *RemoteClient) appendDuration(data []byte, delta time.Duration) ([]byte, string) {
+	p := client.Precision
+
+	// fall back to microseconds as fractional milliseconds.
+	if p.metricType == "us" && !client.microseconds() {
+		p = FractionalMilliseconds(3)
 	}
 
-	if len(rate) > 0 {
-		r = rate[0]
+	switch {
+	case p.metricType == "us":
+		// data := fmt.Sprintf("%d", int64(delta/time.Microsecond))
+		return strconv.AppendInt(data, int64(delta/time.Microsecond), 10), "us"
+	case p.decimals > 0:
+		// data := fmt.Sprintf("%.*f", p.decimals, float64(delta)/float64(time.Millisecond))
+		data = strconv.AppendFloat(data, float64(delta)/float64(time.Millisecond), 'f', p.decimals, 64)
+		return trimZeros(data), "ms"
+	}
+
+	// data := fmt.Sprintf("%d", int64(delta/time.Millisecond))
+	return strconv.AppendInt(data, int64(delta/time.Millisecond), 10), "ms"
+}
+
+// microseconds returns true if the connection's Encoder can send the |us type.
+func (c *connection) microseconds() bool {
+	_, ok := c.encoder.(MicrosecondsEncoder)
+	return ok
+}
+
+// trimZeros removes trailing zeros after the decimal point, and the point
+// if nothing is left after it, so 2.500 is sent as 2.5 and 3.000 as 3.
+// The data must contain a decimal point.
+func trimZeros(data []byte) []byte {
+	for data[len(data)-1] == '0' {
+		data = data[:len(data)-1]
+	}
+
+	if data[len(data)-1] == '.' {
+		data = data[:len(data)-1]
+	}
+
+	return data
+}
+
+// Histogram reports an arbitrary value, such as a payload size or batch length,
+// to the provided stat (plus the prefix). The server computes the percentiles.
+// Rate is optional and follows the same rules as CountMultiple.
+// NaN and infinite values return an *InvalidValueError.
+func (client *RemoteClient) Histogram(stat string, value float64, rate ...float32) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return &InvalidValueError{Stat: stat, Value: value}
+	}
+
+	r := client.rate(rate)
+
+	// data := fmt.Sprintf("%g", value)
+	data := client.buffer(stat, 24)
+	data = strconv.AppendFloat(data, value, 'f', -1, 64)
+
+	return client.submit(stat, data, "h", r)
+}
+
+// Distribution reports an arbitrary value to the provided stat (plus the prefix).
+// Unlike a histogram, a distribution is aggregated globally by the server
+// rather than per host. Rate is optional and follows the same rules as CountMultiple.
+// NaN and infinite values return an *InvalidValueError.
+func (client *RemoteClient) Distribution(stat string, value float64, rate ...float32) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return &InvalidValueError{Stat: stat, Value: value}
 	}
 
-	// data := fmt.Sprintf("%d|ms", int64(delta/time.Millisecond))
-	data := make([]byte, 0, 20)
-	data = strconv.AppendInt(data, int64(delta/time.Millisecond), 10)
-	data = append(data, '|', 'm', 's')
+	r := client.rate(rate)
 
-	return client.submit(stat, data, r)
+	// data := fmt.Sprintf("%g", value)
+	data := client.buffer(stat, 24)
+	data = strconv.AppendFloat(data, value, 'f', -1, 64)
+
+	return client.submit(stat, data, "d", r)
 }
 
 // Gauge set a StatsD gauge value which is an arbitrary value that maintain
 // its value until set to something else.
 // Useful for logging queue sizes on set intervals.
+// Value must be one of Go's integer or float types, or a string holding a number,
+// or a type based on one, such as type QueueLen int, anything else returns an
+// *InvalidValueError. Strings are sent as they are, so "+5" and "-3" change the
+// gauge by 5 and -3, as they always have.
+// Prefer the typed Gauge methods, which do not allocate.
 func (client *RemoteClient) Gauge(stat string, value interface{}) error {
-	dap := fmt.Sprintf("%v|g", value)
-	return client.submit(stat, []byte(dap), 1)
+	switch v := value.(type) {
+	case int:
+		return client.GaugeInt64(stat, int64(v))
+	case int8:
+		return client.GaugeInt64(stat, int64(v))
+	case int16:
+		return client.GaugeInt64(stat, int64(v))
+	case int32:
+		return client.GaugeInt64(stat, int64(v))
+	case int64:
+		return client.GaugeInt64(stat, v)
+	case uint:
+		return client.GaugeUint64(stat, uint64(v))
+	case uint8:
+		return client.GaugeUint64(stat, uint64(v))
+	case uint16:
+		return client.GaugeUint64(stat, uint64(v))
+	case uint32:
+		return client.GaugeUint64(stat, uint64(v))
+	case uint64:
+		return client.GaugeUint64(stat, v)
+	case float32:
+		return client.gaugeFloat(stat, float64(v), 32)
+	case float64:
+		return client.GaugeFloat64(stat, v)
+	case string:
+		return client.gaugeString(stat, v, value)
+	}
+
+	// types based on the numeric and string types, such as type QueueLen int.
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return client.GaugeInt64(stat, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return client.GaugeUint64(stat, v.Uint())
+	case reflect.Float32:
+		return client.gaugeFloat(stat, v.Float(), 32)
+	case reflect.Float64:
+		return client.GaugeFloat64(stat, v.Float())
+	case reflect.String:
+		return client.gaugeString(stat, v.String(), value)
+	}
+
+	return &InvalidValueError{Stat: stat, Value: value}
+}
+
+// gaugeString sends s, the string form of value, if it holds a number.
+func (client *RemoteClient) gaugeString(stat string, s string, value interface{}) error {
+	if !isNumber(s) {
+		return &InvalidValueError{Stat: stat, Value: value}
+	}
+
+	// sent as is, so a leading sign is still a relative change.
+	data := client.buffer(stat, len(s))
+	data = append(data, s...)
+
+	return client.submit(stat, data, "g", 1)
+}
+
+// isNumber returns true if s is a decimal number, such as 5, -3, +1.5 or 2e3.
+func isNumber(s string) bool {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+
+	digits := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		digits++
+	}
+
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+
+	if digits == 0 {
+		return false
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+
+		exponent := i
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		}
+
+		if i == exponent {
+			return false
+		}
+	}
+
+	return i == len(s)
+}
+
+// GaugeInt64 sets the gauge to exactly value.
+// StatsD reads a leading sign as a relative change, so a negative value is sent
+// as a reset to 0 followed by the decrement, both in the same packet.
+func (client *RemoteClient) GaugeInt64(stat string, value int64) error {
+	// data := fmt.Sprintf("%d", value)
+	data := client.buffer(stat, 24)
+	data = strconv.AppendInt(data, value, 10)
+
+	return client.gauge(stat, data, value < 0)
+}
+
+// GaugeUint64 sets the gauge to exactly value.
+func (client *RemoteClient) GaugeUint64(stat string, value uint64) error {
+	// data := fmt.Sprintf("%d", value)
+	data := client.buffer(stat, 24)
+	data = strconv.AppendUint(data, value, 10)
+
+	return client.gauge(stat, data, false)
+}
+
+// GaugeFloat64 sets the gauge to exactly value. NaN and infinite values
+// can not be represented in the protocol and return an *InvalidValueError.
+// Negative values are sent as a reset to 0 followed by the decrement.
+func (client *RemoteClient) GaugeFloat64(stat string, value float64) error {
+	return client.gaugeFloat(stat, value, 64)
+}
+
+// GaugeAbsolute sets the gauge to exactly value, it is the same as GaugeFloat64.
+func (client *RemoteClient) GaugeAbsolute(stat string, value float64) error {
+	return client.GaugeFloat64(stat, value)
+}
+
+func (client *RemoteClient) gaugeFloat(stat string, value float64, bitSize int) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return &InvalidValueError{Stat: stat, Value: value}
+	}
+
+	// -0 would be sent as a decrement of 0, rather than setting 0.
+	if value == 0 {
+		value = 0
+	}
+
+	// data := fmt.Sprintf("%g", value)
+	data := client.buffer(stat, 24)
+	data = strconv.AppendFloat(data, value, 'f', -1, bitSize)
+
+	return client.gauge(stat, data, value < 0)
+}
+
+// gauge submits the absolute gauge value. If negative, it is preceded
+// by a reset to 0 so the server does not read it as a decrement.
+func (client *RemoteClient) gauge(stat string, data []byte, negative bool) error {
+	if !negative {
+		return client.submit(stat, data, "g", 1)
+	}
+
+	message := make([]byte, 0, 2*(len(client.prefix)+len(stat)+4)+len(data)+1)
+	message = client.encode(message, client.metric(stat, []byte{'0'}, "g", 1))
+	message = append(message, '\n')
+	message = client.encode(message, client.metric(stat, data, "g", 1))
+
+	return client.write(message)
 }
 
-// Close flushes the buffer and closes the connection.
+// GaugeDelta changes the gauge's current value by delta, which may be negative.
+// Useful for values, such as in-flight requests, that are updated from many
+// places without a shared counter.
+func (client *RemoteClient) GaugeDelta(stat string, delta float64) error {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return &InvalidValueError{Stat: stat, Value: delta}
+	}
+
+	// -0 would be sent as +-0.
+	if delta == 0 {
+		delta = 0
+	}
+
+	// data := fmt.Sprintf("%+g", delta)
+	data := client.buffer(stat, 24)
+	if delta >= 0 {
+		data = append(data, '+')
+	}
+	data = strconv.AppendFloat(data, delta, 'f', -1, 64)
+
+	return client.submit(stat, data, "g", 1)
+}
+
+// Set adds the value to a StatsD set. The server counts the number of
+// unique values received for the stat during each flush interval.
+// Useful for tracking things like unique users or devices.
+// Any ':', '|' or new line in the value is replaced with '_', so addresses
+// such as host:port are counted as one value rather than split by the server.
+func (client *RemoteClient) Set(stat string, value string) error {
+	// ':', '|' and new lines are replaced so the value can not break the line.
+	data := client.buffer(stat, len(value))
+	data = appendSanitized(data, value, ":|\n")
+
+	return client.submit(stat, data, "s", 1)
+}
+
+// Close flushes the buffer and any packed values, and closes the connection.
 func (client *RemoteClient) Close() error {
-	client.writeMutex.Lock()
+	return client.CloseContext(context.Background())
+}
+
+// CloseContext flushes the buffer and any packed values, and closes the connection,
+// but stops waiting for a blocked write when the context ends, returning its error.
+// The client is closed either way, and the connection is closed as soon as the
+// blocked write finishes, which WithWriteTimeout limits.
+func (client *RemoteClient) CloseContext(ctx context.Context) error {
+	client.FlushContext(ctx)
+	client.closeOnce.Do(func() {
+		if client.done != nil {
+			close(client.done)
+		}
+		client.reconnector.close()
+	})
+
+	err := client.writeMutex.LockContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer client.writeMutex.Unlock()
 
-	if client.buf != nil {
-		client.buf.Flush()
-		client.buf = nil
+	return client.closeTransport(ctx)
+}
+
+// closeTransport flushes and closes the transport, if there is one.
+// Must be called with the writeMutex held.
+func (client *RemoteClient) closeTransport(ctx context.Context) error {
+	// a lazy client may never have connected.
+	if client.transport == nil {
+		return nil
+	}
+
+	if t, ok := client.transport.(*netTransport); ok {
+		stop := t.setDeadline(ctx)
+		defer stop()
+	}
+
+	client.transport.Flush()
+	err := client.transport.Close()
+	client.transport = nil
+
+	return err
+}
+
+// rate returns the first of the provided rates, or the client's DefaultRate,
+// or the global statsd.DefaultRate if the client's is zero.
+func (client *RemoteClient) rate(rate []float32) float32 {
+	if len(rate) > 0 {
+		return rate[0]
+	}
+
+	if client.DefaultRate != 0 {
+		return client.DefaultRate
 	}
 
-	return client.conn.Close()
+	return DefaultRate
 }
 
-// submit formats the statsd event data, handles sampling, and prepares it,
-// and sends it to the server.
-func (client *RemoteClient) submit(stat string, value []byte, rate float32) error {
+// submit handles sampling, formats the statsd event data using
+// the connection's Encoder and sends it to the server.
+func (client *RemoteClient) submit(stat string, value []byte, metricType string, rate float32) error {
 	if rate == 0 {
 		return nil
 	}
 
-	if rate < 1 {
+	if rate < 1 && !client.sampled {
 		randLock.Lock() // rand objects are not thread safe.
 		r := randSource.Float32()
 		randLock.Unlock()
 
-		if r < rate {
-			// value = fmt.Sprintf("%s|@%f", value, rate)
-			value = append(value, '|', '@')
-			value = strconv.AppendFloat(value, float64(rate), 'f', -1, 32)
-		} else {
+		if r >= rate {
 			return nil
 		}
 	}
 
-	message := make([]byte, 0, len(client.prefix)+len(stat)+len(value)+3)
+	// the message is encoded into the value's spare capacity to save an allocation.
+	m := client.metric(stat, value, metricType, rate)
+	if client.packer != nil && packable(metricType) {
+		return client.pack(m)
+	}
+
+	message := value[len(value):]
+	message = client.encode(message, m)
+
+	return client.write(message)
+}
+
+// buffer returns a buffer for a value of up to valueSize bytes with enough spare
+// capacity for submit to encode the stat, with the client's prefix and tags, after it.
+func (client *RemoteClient) buffer(stat string, valueSize int) []byte {
+	size := 2*valueSize + len(client.prefix) + len(stat) + 32
+	for _, tag := range client.Tags {
+		size += len(tag) + 1
+	}
+
+	return make([]byte, 0, size)
+}
 
-	if len(client.prefix) != 0 {
-		message = append(message, client.prefix...)
-		message = append(message, '.')
+// metric returns the Metric for the stat with the client's prefix and tags.
+func (client *RemoteClient) metric(stat string, value []byte, metricType string, rate float32) Metric {
+	return Metric{
+		Prefix:    client.prefix,
+		Name:      stat,
+		Value:     value,
+		Type:      metricType,
+		Rate:      rate,
+		Tags:      client.Tags,
+		Timestamp: client.timestamp,
+
+		ContainerID: client.containerID,
 	}
+}
 
-	// This loop removes the need for the intermediate string -> []byte conversion into append
-	// message = append(message, []byte(stat)...)
-	for i := 0; i < len(stat); i++ {
-		message = append(message, stat[i])
+// encode appends the metric to the message using the connection's Encoder,
+// or the DogStatsDEncoder if none was set.
+func (c *connection) encode(message []byte, m Metric) []byte {
+	if c.encoder == nil {
+		return DogStatsDEncoder{}.Encode(message, m)
 	}
 
-	message = append(message, ':')
-	message = append(message, value...)
+	return c.encoder.Encode(message, m)
+}
+
+// write sends the message, reconnecting and trying again once on error.
+func (client *RemoteClient) write(message []byte) error {
+	return client.writeContext(context.Background(), message)
+}
+
+// writeContext is write, but stops waiting when the context ends.
+func (client *RemoteClient) writeContext(ctx context.Context, message []byte) error {
+	_, err := client.send(ctx, message)
+	if err != nil && ctx.Err() != nil {
+		// not worth waiting for a reconnect, a failed stream is
+		// reconnected by the next write.
+		return ctx.Err()
+	}
+
+	if err == ErrSocketBufferFull {
+		// the connection is fine, reconnecting will not help the server catch up.
+		return err
+	}
+
+	if err == ErrNotConnected {
+		// still connecting in the background.
+		return err
+	}
 
-	_, err := client.send(message)
 	if err != nil {
 		connectError := client.connect()
 
 		// reconnect succeeded so try again with this one.
 		if connectError == nil {
-			_, err := client.send(message)
+			_, err := client.send(ctx, message)
 			return err
 		}
 
@@ -340,16 +1167,41 @@ func (client *RemoteClient) submit(stat string, value []byte, rate float32) erro
 	return nil
 }
 
-// sends the data to the server endpoint over the net.Conn
-func (client *RemoteClient) send(data []byte) (int, error) {
-	client.writeMutex.Lock()
+// sends the data to the server using the transport.
+func (client *RemoteClient) send(ctx context.Context, data []byte) (int, error) {
+	err := client.writeMutex.LockContext(ctx)
+	if err != nil {
+		return 0, err
+	}
 	defer client.writeMutex.Unlock()
 
-	if client.buf == nil {
+	if client.transport == nil {
+		if client.lazy && !client.closed() {
+			client.dropped.Add(1)
+			return 0, ErrNotConnected
+		}
+
+		return 0, ErrConnectionClosed
+	}
+
+	if client.closed() {
+		client.closeTransport(ctx)
 		return 0, ErrConnectionClosed
 	}
 
-	n, err := client.buf.Write(data)
+	// Close may give up waiting for this write, leaving the transport to be closed after it.
+	defer func() {
+		if client.closed() {
+			client.closeTransport(ctx)
+		}
+	}()
+
+	if t, ok := client.transport.(*netTransport); ok {
+		stop := t.setDeadline(ctx)
+		defer stop()
+	}
+
+	n, err := client.transport.Write(data)
 	if err != nil {
 		return 0, err
 	}
@@ -359,7 +1211,7 @@ func (client *RemoteClient) send(data []byte) (int, error) {
 	}
 
 	// TOOD: figure out if we really need to do a buffer flush after every metric.
-	err = client.buf.Flush()
+	err = client.transport.Flush()
 	if err != nil {
 		return n, err
 	}
@@ -382,16 +1234,61 @@ func (NoopClient) Measure(stat string, delta time.Duration, rate ...float32) err
 	return nil
 }
 
+// Histogram on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) Histogram(stat string, value float64, rate ...float32) error {
+	return nil
+}
+
+// Distribution on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) Distribution(stat string, value float64, rate ...float32) error {
+	return nil
+}
+
 // Gauge on NoopClient is a noop and does not require and internet connection.
 func (NoopClient) Gauge(stat string, value interface{}) error {
 	return nil
 }
 
+// GaugeInt64 on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) GaugeInt64(stat string, value int64) error {
+	return nil
+}
+
+// GaugeUint64 on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) GaugeUint64(stat string, value uint64) error {
+	return nil
+}
+
+// GaugeFloat64 on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) GaugeFloat64(stat string, value float64) error {
+	return nil
+}
+
+// GaugeAbsolute on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) GaugeAbsolute(stat string, value float64) error {
+	return nil
+}
+
+// GaugeDelta on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) GaugeDelta(stat string, delta float64) error {
+	return nil
+}
+
+// Set on NoopClient is a noop and does not require and internet connection.
+func (NoopClient) Set(stat string, value string) error {
+	return nil
+}
+
 // Substater on NoopClient is a noop and does not require and internet connection.
 func (n NoopClient) Substater(extraPrefix ...string) Stater {
 	return n
 }
 
+// WithTags on NoopClient is a noop and does not require and internet connection.
+func (n NoopClient) WithTags(tags ...string) Stater {
+	return n
+}
+
 // SetDefaultRate on NoopClient is a noop and does not require and internet connection.
 func (NoopClient) SetDefaultRate(rate float32) {
 }
@@ -4,18 +4,38 @@ package statsd
 import (
 	"bufio"
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
 
+// bufferTransport is a Transport writing the stats to a buffer.
+type bufferTransport struct {
+	*bytes.Buffer
+}
+
+func (bufferTransport) Flush() error { return nil }
+func (bufferTransport) Close() error { return nil }
+
 func NewTestClient(prefix string) (*RemoteClient, *bytes.Buffer) {
 	b := &bytes.Buffer{}
-	buf := bufio.NewReadWriter(bufio.NewReader(b), bufio.NewWriter(b))
-	c := &RemoteClient{
-		prefix: []byte(prefix),
-		connection: &connection{
-			buf: buf,
-		},
+	c, err := NewWithOptions("test", WithPrefix(prefix), WithTransport(func() (Transport, error) {
+		return bufferTransport{b}, nil
+	}))
+	if err != nil {
+		panic(err)
 	}
 	return c, b
 }
@@ -27,7 +47,16 @@ func TestNoopClient(t *testing.T) {
 	noop.Count("stat")
 	noop.CountMultiple("stat", 3)
 	noop.Measure("stat", time.Second)
+	noop.Histogram("stat", 1.5)
+	noop.Distribution("stat", 1.5)
 	noop.Gauge("stat", 1)
+	noop.GaugeInt64("stat", 1)
+	noop.GaugeUint64("stat", 1)
+	noop.GaugeFloat64("stat", 1)
+	noop.GaugeAbsolute("stat", -1)
+	noop.GaugeDelta("stat", 1)
+	noop.Set("stat", "value")
+	noop.WithTags("key:value").Count("stat")
 	noop.Close()
 
 	noopPointer := &NoopClient{}
@@ -36,7 +65,16 @@ func TestNoopClient(t *testing.T) {
 	noopPointer.Count("stat")
 	noopPointer.CountMultiple("stat", 4)
 	noopPointer.Measure("stat", time.Second)
+	noopPointer.Histogram("stat", 1.5)
+	noopPointer.Distribution("stat", 1.5)
 	noopPointer.Gauge("stat", 1)
+	noopPointer.GaugeInt64("stat", 1)
+	noopPointer.GaugeUint64("stat", 1)
+	noopPointer.GaugeFloat64("stat", 1)
+	noopPointer.GaugeAbsolute("stat", -1)
+	noopPointer.GaugeDelta("stat", 1)
+	noopPointer.Set("stat", "value")
+	noopPointer.WithTags("key:value").Count("stat")
 	noopPointer.Close()
 }
 
@@ -45,7 +83,15 @@ func TestDefaultClient(t *testing.T) {
 	Count("stat")
 	CountMultiple("stat", 4)
 	Measure("stat", time.Second)
+	Histogram("stat", 1.5)
+	Distribution("stat", 1.5)
 	Gauge("stat", 1)
+	GaugeInt64("stat", 1)
+	GaugeUint64("stat", 1)
+	GaugeFloat64("stat", 1)
+	GaugeAbsolute("stat", -1)
+	GaugeDelta("stat", 1)
+	Set("stat", "value")
 }
 
 func TestNew(t *testing.T) {
@@ -58,32 +104,395 @@ func TestNew(t *testing.T) {
 		t.Error("invalid address, should have returned error")
 	}
 
-	// without prefix
-	client, _ := New("0.0.0.0:1000")
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
 
-	b := &bytes.Buffer{}
-	client.buf = bufio.NewReadWriter(bufio.NewReader(b), bufio.NewWriter(b))
+	// without prefix
+	client, err := New(l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
 
 	client.Count("test", 1)
+
+	b := make([]byte, 1024)
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := l.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	expected := "test:1|c"
-	if b := b.String(); b != expected {
-		t.Fatalf("expected %s, got %s", expected, b)
+	if p := string(b[:n]); p != expected {
+		t.Fatalf("expected %s, got %s", expected, p)
 	}
 
 	// with prefix
-	client2, err := New("0.0.0.0:1000", "prefix")
+	client2, err := New(l.LocalAddr().String(), "prefix")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer client2.Close()
 
-	b.Reset()
-	client2.buf = client.buf
-
 	client2.Count("test")
+
+	n, _, err = l.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	expected = "prefix.test:1|c"
-	if b := b.String(); b != expected {
-		t.Fatalf("expected %s, got %s", expected, b)
+	if p := string(b[:n]); p != expected {
+		t.Fatalf("expected %s, got %s", expected, p)
+	}
+}
+
+func TestNewNetwork(t *testing.T) {
+	cases := []struct {
+		address string
+		network string
+		host    string
+	}{
+		{"0.0.0.0:1000", "udp", "0.0.0.0:1000"},
+		{"udp://0.0.0.0:1000", "udp", "0.0.0.0:1000"},
+		{"udp4://0.0.0.0:1000", "udp4", "0.0.0.0:1000"},
+		{"tcp://statsd:8125", "tcp", "statsd:8125"},
+	}
+
+	for _, tc := range cases {
+		network, host, err := splitNetwork(tc.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if network != tc.network || host != tc.host {
+			t.Errorf("%s: expected %s %s, got %s %s", tc.address, tc.network, tc.host, network, host)
+		}
+	}
+
+	_, err := New("http://0.0.0.0:1000")
+	if err == nil {
+		t.Error("unsupported network, should have returned error")
+	}
+}
+
+func TestNewTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	testStream(t, l, "tcp://"+l.Addr().String())
+}
+
+func TestNewTLS(t *testing.T) {
+	serverCert, serverPool := newTestCertificate(t)
+	clientCert, clientPool := newTestCertificate(t)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// without a network uses TCP
+	testStream(t, l, l.Addr().String(), WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      serverPool,
+		ServerName:   "statsd.test",
+	}))
+
+	// unknown server certificate
+	_, err = NewWithOptions(l.Addr().String(), WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		ServerName:   "statsd.test",
+	}))
+	if err == nil {
+		t.Error("unknown certificate, should have returned error")
+	}
+
+	_, err = NewWithOptions("unixgram:///var/run/statsd.sock", WithTLS(&tls.Config{}))
+	if err == nil {
+		t.Error("unixgram, should have returned error")
+	}
+
+	// an explicit udp network is not switched to TCP
+	_, err = NewWithOptions("udp://"+l.Addr().String(), WithTLS(&tls.Config{}))
+	if err == nil || !strings.Contains(err.Error(), "udp") {
+		t.Errorf("udp, should have returned error, got %v", err)
+	}
+}
+
+func TestNewTLSHandshakeTimeout(t *testing.T) {
+	// the server accepts connections but never completes the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	start := time.Now()
+	_, err = NewWithOptions("tcp://"+l.Addr().String(), WithTLS(&tls.Config{ServerName: "statsd.test"}), WithDialTimeout(50*time.Millisecond))
+	if err == nil {
+		t.Fatal("should have returned the handshake error")
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("should have given up on the handshake, took %v", d)
+	}
+}
+
+// newTestCertificate returns a self signed certificate for statsd.test
+// and a pool with it as the root.
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "statsd.test"},
+		DNSNames:              []string{"statsd.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestNewUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	testStream(t, l, "unix://"+path)
+}
+
+// testStream checks the client sends newline framed stats over a stream
+// connection to the listener, and reconnects when the server closes it.
+func testStream(t *testing.T, l net.Listener, address string, options ...Option) {
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			// the client waits for the TLS handshake when connecting.
+			if tlsConn, ok := conn.(*tls.Conn); ok {
+				if err := tlsConn.Handshake(); err != nil {
+					conn.Close()
+					continue
+				}
+			}
+			conns <- conn
+		}
+	}()
+
+	options = append(options, WithPrefix("prefix"), WithReconnectDelay(0))
+	c, err := NewWithOptions(address, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	conn := <-conns
+	r := bufio.NewReader(conn)
+
+	// each stat on its own line
+	c.Count("count")
+	c.Gauge("gauge", -1)
+
+	expected := []string{"prefix.count:1|c\n", "prefix.gauge:0|g\n", "prefix.gauge:-1|g\n"}
+	for _, e := range expected {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if line != e {
+			t.Fatalf("expected %q, got %q", e, line)
+		}
+	}
+
+	// reconnects when the server closes the connection
+	conn.Close()
+
+	var newConn net.Conn
+	for i := 0; i < 100 && newConn == nil; i++ {
+		c.Count("reconnect")
+
+		select {
+		case newConn = <-conns:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if newConn == nil {
+		t.Fatal("should have reconnected")
+	}
+	defer newConn.Close()
+
+	line, err := bufio.NewReader(newConn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := "prefix.reconnect:1|c\n"; line != e {
+		t.Fatalf("expected %q, got %q", e, line)
+	}
+}
+
+func TestNewUnixgram(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "statsd.sock")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewWithOptions("unixgram://"+path, WithPrefix("prefix"), WithReconnectDelay(0))
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 1024)
+	n, err := l.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p, e := string(b[:n]), "prefix.count:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
+	}
+
+	// reconnects when the socket file is recreated
+	l.Close()
+	os.Remove(path)
+
+	l, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the first write after the reconnect delay reconnects.
+	for i := 0; i < 100; i++ {
+		err = c.Count("reconnect")
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = l.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p, e := string(b[:n]), "prefix.reconnect:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
+	}
+
+	// full socket buffer when the server stops reading
+	for i := 0; i < 100000; i++ {
+		err = c.Count("full")
+		if err != nil {
+			break
+		}
+	}
+
+	if err != ErrSocketBufferFull {
+		t.Fatalf("expected ErrSocketBufferFull, got %v", err)
+	}
+
+	// usable again once the server catches up
+	for {
+		l.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		if _, err := l.Read(b); err != nil {
+			break
+		}
+	}
+
+	err = c.Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	n, err = l.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p, e := string(b[:n]), "prefix.count:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
 	}
 }
 
@@ -120,7 +529,7 @@ func TestRemoteClientSubstater(t *testing.T) {
 	defer c.Close()
 
 	c2 := c.Substater("extra").(*RemoteClient)
-	if c.conn != c2.conn {
+	if c.transport != c2.transport {
 		t.Errorf("should have some connection")
 	}
 
@@ -145,6 +554,109 @@ func TestRemoteClientSubstater(t *testing.T) {
 	}
 }
 
+func TestRemoteClientWithTags(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+	c.Tags = []string{"env:prod"}
+
+	tagged := c.WithTags("route:/upload", "canary")
+	if tagged.(*RemoteClient).connection != c.connection {
+		t.Errorf("should have same connection")
+	}
+
+	cases := []struct {
+		name     string
+		send     func(s Stater) error
+		expected string
+	}{
+		{"count", func(s Stater) error { return s.Count("c") }, "prefix.c:1|c|#env:prod,route:/upload,canary"},
+		{"count multiple", func(s Stater) error { return s.CountMultiple("c", 3) }, "prefix.c:3|c|#env:prod,route:/upload,canary"},
+		{"rate", func(s Stater) error { return s.Count("c", 0.999999) }, "prefix.c:1|c|@0.999999|#env:prod,route:/upload,canary"},
+		{"measure", func(s Stater) error { return s.Measure("m", time.Second) }, "prefix.m:1000|ms|#env:prod,route:/upload,canary"},
+		{"gauge", func(s Stater) error { return s.Gauge("g", 5) }, "prefix.g:5|g|#env:prod,route:/upload,canary"},
+		{"negative gauge", func(s Stater) error { return s.Gauge("g", -5) }, "prefix.g:0|g|#env:prod,route:/upload,canary\nprefix.g:-5|g|#env:prod,route:/upload,canary"},
+	}
+
+	for _, tc := range cases {
+		buf.Reset()
+		err := tc.send(tagged)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if b := buf.String(); b != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, b)
+		}
+	}
+
+	// the original client only has its own tags
+	buf.Reset()
+	c.Count("c")
+
+	expected := "prefix.c:1|c|#env:prod"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// substaters keep the tags
+	buf.Reset()
+	tagged.Substater("sub").Count("c")
+
+	expected = "prefix.sub.c:1|c|#env:prod,route:/upload,canary"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// reserved characters are replaced
+	buf.Reset()
+	c.Tags = nil
+	c.WithTags("a|b", "c,d", "e#f").Count("c")
+
+	expected = "prefix.c:1|c|#a_b,c_d,e_f"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestRemoteClientWithTimestamp(t *testing.T) {
+	c, buf := NewTestClient("prefix")
+	ts := time.Unix(1400000000, 0)
+
+	stamped := c.WithTimestamp(ts)
+	if stamped.connection != c.connection {
+		t.Errorf("should have same connection")
+	}
+
+	err := stamped.CountMultiple("count", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "prefix.count:3|c|T1400000000"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = stamped.WithTags("env:prod").Gauge("gauge", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "prefix.gauge:10|g|#env:prod|T1400000000"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// the original client does not send a timestamp
+	buf.Reset()
+	c.Count("count")
+
+	expected = "prefix.count:1|c"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
 func TestCount(t *testing.T) {
 	c, buf := NewTestClient("default")
 	DefaultClient = c
@@ -385,6 +897,83 @@ func TestClientMeasure(t *testing.T) {
 	}
 }
 
+func TestClientMeasurePrecision(t *testing.T) {
+	c, buf := NewTestClient("test")
+
+	cases := []struct {
+		precision Precision
+		delta     time.Duration
+		expected  string
+	}{
+		{Precision{}, 2800 * time.Microsecond, "test.timing:2|ms"},
+		{Milliseconds, 2800 * time.Microsecond, "test.timing:2|ms"},
+		{Milliseconds, 999 * time.Microsecond, "test.timing:0|ms"},
+		{FractionalMilliseconds(3), 2800 * time.Microsecond, "test.timing:2.8|ms"},
+		{FractionalMilliseconds(3), 1234567 * time.Nanosecond, "test.timing:1.235|ms"},
+		{FractionalMilliseconds(2), 999 * time.Microsecond, "test.timing:1|ms"},
+		{FractionalMilliseconds(3), 42 * time.Microsecond, "test.timing:0.042|ms"},
+		{FractionalMilliseconds(3), 0, "test.timing:0|ms"},
+		{FractionalMilliseconds(3), time.Second, "test.timing:1000|ms"},
+		{Microseconds, 2800 * time.Microsecond, "test.timing:2.8|ms"},
+		{Microseconds, 1500 * time.Nanosecond, "test.timing:0.002|ms"},
+	}
+
+	for _, tc := range cases {
+		buf.Reset()
+		c.Precision = tc.precision
+
+		err := c.Measure("timing", tc.delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b := buf.String(); b != tc.expected {
+			t.Errorf("%v: expected %s, got %s", tc.delta, tc.expected, b)
+		}
+	}
+
+	// substaters keep the precision
+	buf.Reset()
+	c.Precision = Microseconds
+	c.Substater("sub").Measure("timing", time.Millisecond)
+
+	expected := "test.sub.timing:1|ms"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// dialects without |us
+	for _, encoder := range []Encoder{DogStatsDEncoder{}, EtsyEncoder{}, InfluxDBEncoder{}, GraphiteEncoder{}, SignalFxEncoder{}} {
+		buf.Reset()
+		c.encoder = encoder
+		c.Measure("timing", 2800*time.Microsecond)
+
+		expected = "test.timing:2.8|ms"
+		if b := buf.String(); b != expected {
+			t.Errorf("%T: expected %s, got %s", encoder, expected, b)
+		}
+	}
+
+	// servers accepting |us
+	buf.Reset()
+	c.encoder = MicrosecondsEncoder{}
+	c.Substater("sub").WithTags("env:prod").Measure("timing", 1500*time.Nanosecond)
+
+	expected = "test.sub.timing:1|us|#env:prod"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	c.encoder = MicrosecondsEncoder{Encoder: InfluxDBEncoder{}}
+	c.WithTags("env:prod").Measure("timing", 2800*time.Microsecond)
+
+	expected = "test.timing,env=prod:2800|us"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
 func TestMeasure(t *testing.T) {
 	c, buf := NewTestClient("default")
 	DefaultClient = c
@@ -429,16 +1018,162 @@ func TestMeasure(t *testing.T) {
 	Measure("measure", time.Second) // should not panic
 }
 
-func TestGauge(t *testing.T) {
-	c, buf := NewTestClient("stub")
+func TestHistogram(t *testing.T) {
+	c, buf := NewTestClient("default")
 	DefaultClient = c
 
-	err := Gauge("measure", 10.5)
+	err := Histogram("size", 512)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expected := "stub.measure:10.5|g"
+	expected := "default.size:512|h"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	DefaultClient = nil
+	Histogram("size", 512) // should not panic
+}
+
+func TestClientHistogram(t *testing.T) {
+	c, buf := NewTestClient("test")
+
+	err := c.Histogram("size", 1024.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test.size:1024.25|h"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// with rate
+	buf.Reset()
+	err = c.Histogram("size", 3, 0.999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.size:3|h|@0.999999"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// below rate, should not fill buffer
+	buf.Reset()
+	err = c.Histogram("size", 3, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = ""
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// with client rate
+	buf.Reset()
+	c.DefaultRate = 0.999999
+	err = c.Histogram("size", -7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.size:-7|h|@0.999999"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	c, buf := NewTestClient("default")
+	DefaultClient = c
+
+	err := Distribution("batch", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "default.batch:42|d"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	DefaultClient = nil
+	Distribution("batch", 42) // should not panic
+}
+
+func TestClientDistribution(t *testing.T) {
+	c, buf := NewTestClient("test")
+
+	err := c.Distribution("batch", 0.125)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test.batch:0.125|d"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// with rate
+	buf.Reset()
+	err = c.Distribution("batch", 10, 0.999999)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.batch:10|d|@0.999999"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// with client rate
+	buf.Reset()
+	c.DefaultRate = 0.999999
+	err = c.Distribution("batch", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "test.batch:10|d|@0.999999"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestClientHistogramInvalidValue(t *testing.T) {
+	c, buf := NewTestClient("test")
+
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		err := c.Histogram("size", v)
+		if e, ok := err.(*InvalidValueError); !ok || e.Stat != "size" {
+			t.Errorf("%v: expected InvalidValueError, got %v", v, err)
+		}
+
+		err = c.Distribution("batch", v)
+		if e, ok := err.(*InvalidValueError); !ok || e.Stat != "batch" {
+			t.Errorf("%v: expected InvalidValueError, got %v", v, err)
+		}
+	}
+
+	if b := buf.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	c, buf := NewTestClient("stub")
+	DefaultClient = c
+
+	err := Gauge("measure", 10.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.measure:10.5|g"
 	if b := buf.String(); b != expected {
 		t.Fatalf("expected %s, got %s", expected, b)
 	}
@@ -461,6 +1196,327 @@ func TestClientGauge(t *testing.T) {
 	}
 }
 
+// named types, as callers may pass to Gauge.
+type (
+	queueLen   int
+	bytesFree  uint32
+	loadAvg    float64
+	gaugeDelta string
+)
+
+func TestClientGaugeTypes(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{int(3), "stub.g:3|g"},
+		{int8(-3), "stub.g:0|g\nstub.g:-3|g"},
+		{int16(3), "stub.g:3|g"},
+		{int32(3), "stub.g:3|g"},
+		{int64(3), "stub.g:3|g"},
+		{uint(3), "stub.g:3|g"},
+		{uint8(3), "stub.g:3|g"},
+		{uint16(3), "stub.g:3|g"},
+		{uint32(3), "stub.g:3|g"},
+		{uint64(18446744073709551615), "stub.g:18446744073709551615|g"},
+		{float32(0.1), "stub.g:0.1|g"},
+		{float64(0.1), "stub.g:0.1|g"},
+		{"12.5", "stub.g:12.5|g"},
+		{"+5", "stub.g:+5|g"},
+		{"-3", "stub.g:-3|g"},
+		{"1e3", "stub.g:1e3|g"},
+		{queueLen(42), "stub.g:42|g"},
+		{bytesFree(7), "stub.g:7|g"},
+		{loadAvg(0.5), "stub.g:0.5|g"},
+		{gaugeDelta("+2"), "stub.g:+2|g"},
+		{time.Millisecond, "stub.g:1000000|g"},
+	}
+
+	for _, tc := range cases {
+		buf.Reset()
+		err := c.Gauge("g", tc.value)
+		if err != nil {
+			t.Fatalf("%T: %v", tc.value, err)
+		}
+
+		if b := buf.String(); b != tc.expected {
+			t.Errorf("%T: expected %s, got %s", tc.value, tc.expected, b)
+		}
+	}
+
+	// values that can not be represented
+	invalid := []interface{}{nil, struct{}{}, "abc", "", "-", ".", "1e", "NaN", "Inf", "0x10", "1|c", "5\n", gaugeDelta("abc"), loadAvg(math.NaN()), math.NaN(), math.Inf(1), float32(math.Inf(-1))}
+	for _, v := range invalid {
+		buf.Reset()
+		err := c.Gauge("g", v)
+		if _, ok := err.(*InvalidValueError); !ok {
+			t.Errorf("%#v: expected InvalidValueError, got %v", v, err)
+		}
+
+		if b := buf.String(); b != "" {
+			t.Errorf("%#v: should not have written, got %s", v, b)
+		}
+	}
+}
+
+func TestClientGaugeInt64(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	err := c.GaugeInt64("queue", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.queue:42|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = c.GaugeInt64("queue", -42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "stub.queue:0|g\nstub.queue:-42|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestClientGaugeUint64(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	err := c.GaugeUint64("queue", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.queue:42|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestClientGaugeFloat64(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	err := c.GaugeFloat64("load", 0.75)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.load:0.75|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// negative zero sets 0
+	buf.Reset()
+	err = c.GaugeFloat64("load", math.Copysign(0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "stub.load:0|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = c.GaugeFloat64("load", math.NaN())
+	if e, ok := err.(*InvalidValueError); !ok || e.Stat != "load" {
+		t.Fatalf("expected InvalidValueError, got %v", err)
+	}
+
+	err = c.GaugeDelta("load", math.Inf(-1))
+	if _, ok := err.(*InvalidValueError); !ok {
+		t.Fatalf("expected InvalidValueError, got %v", err)
+	}
+
+	if b := buf.String(); b != "" {
+		t.Fatalf("should not have written, got %s", b)
+	}
+}
+
+func TestGaugeAbsolute(t *testing.T) {
+	c, buf := NewTestClient("stub")
+	DefaultClient = c
+
+	err := GaugeAbsolute("queue", -3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.queue:0|g\nstub.queue:-3|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	DefaultClient = nil
+	GaugeAbsolute("queue", -3) // should not panic
+}
+
+func TestClientGaugeAbsolute(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	err := c.GaugeAbsolute("queue", 10.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.queue:10.5|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// negative values are sent as a reset then a decrement
+	buf.Reset()
+	err = c.GaugeAbsolute("queue", -2.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "stub.queue:0|g\nstub.queue:-2.5|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// without a prefix
+	c, buf = NewTestClient("")
+	err = c.GaugeAbsolute("queue", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "queue:0|g\nqueue:-1|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestGaugeDelta(t *testing.T) {
+	c, buf := NewTestClient("stub")
+	DefaultClient = c
+
+	err := GaugeDelta("inflight", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.inflight:+1|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	DefaultClient = nil
+	GaugeDelta("inflight", 1) // should not panic
+}
+
+func TestClientGaugeDelta(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	err := c.GaugeDelta("inflight", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.inflight:+5|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = c.GaugeDelta("inflight", -3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "stub.inflight:-3|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = c.GaugeDelta("inflight", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "stub.inflight:+0|g"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	buf.Reset()
+	err = c.GaugeDelta("inflight", math.Copysign(0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestSet(t *testing.T) {
+	c, buf := NewTestClient("stub")
+	DefaultClient = c
+
+	err := Set("users", "gopher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.users:gopher|s"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	DefaultClient = nil
+	Set("users", "gopher") // should not panic
+}
+
+func TestClientSet(t *testing.T) {
+	c, buf := NewTestClient("stub")
+
+	err := c.Set("devices", "765")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stub.devices:765|s"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// without a prefix
+	c, buf = NewTestClient("")
+	err = c.Set("devices", "765")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "devices:765|s"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// reserved characters
+	buf.Reset()
+	err = c.Set("devices", "a|b:c\nd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "devices:a_b_c_d|s"
+	if b := buf.String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
 func TestEmptyPrefix(t *testing.T) {
 	c, buf := NewTestClient("")
 
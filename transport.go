@@ -0,0 +1,182 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Transport sends the encoded stats to the server. The client writes each
+// message, one or more stats separated by new lines, and flushes it straight
+// away, holding a lock so the methods are never called concurrently.
+//
+// If Write or Flush fail the client opens a new transport, backing off like
+// any reconnect, and closes the failed one. The exception is ErrSocketBufferFull,
+// which means the message was dropped but the transport can still be used.
+type Transport interface {
+	// Write buffers the message, returning the number of bytes written.
+	Write(message []byte) (int, error)
+
+	// Flush sends any buffered messages.
+	Flush() error
+
+	// Close closes the transport, it is not used again.
+	Close() error
+}
+
+// WithTransport sends the stats using the transports returned by open, rather
+// than dialing the address, which then only names the server in ActiveAddress.
+// open is called to connect, and again to reconnect after a failed write.
+// Options about dialing, such as TLS, resolving and failover, do not apply.
+//
+//	client, err := statsd.NewWithOptions("memory", statsd.WithTransport(func() (statsd.Transport, error) {
+//		return &memoryTransport{}, nil
+//	}))
+func WithTransport(open func() (Transport, error)) Option {
+	return func(client *RemoteClient) {
+		client.openTransport = open
+	}
+}
+
+// WithDialer dials the server using the dialer, for example to bind a local
+// address, set a timeout or set socket options such as SO_SNDBUF using Control.
+func WithDialer(dialer *net.Dialer) Option {
+	return WithDialFunc(dialer.DialContext)
+}
+
+// WithDialFunc dials the server using dial, rather than net.Dial, such as to go
+// through a proxy. The network and address are from the client's address.
+// TLS, if configured, is added on top of the returned connection.
+func WithDialFunc(dial func(ctx context.Context, network, address string) (net.Conn, error)) Option {
+	return func(client *RemoteClient) {
+		client.dialContext = dial
+	}
+}
+
+// netTransport is the Transport over a net.Conn, the default. Over stream
+// connections each message is followed by a new line, and over unixgram writes
+// time out rather than blocking when the server falls behind.
+type netTransport struct {
+	network      string
+	conn         net.Conn
+	w            *bufio.Writer
+	writeTimeout time.Duration
+
+	// deadline is true if the connection may have a write deadline set.
+	deadline bool
+}
+
+func newNetTransport(network string, conn net.Conn, writeTimeout time.Duration) *netTransport {
+	if writeTimeout <= 0 && network == "unixgram" {
+		writeTimeout = unixgramWriteTimeout
+	}
+
+	return &netTransport{
+		network:      network,
+		conn:         conn,
+		w:            bufio.NewWriter(conn),
+		writeTimeout: writeTimeout,
+	}
+}
+
+// setDeadline sets the deadline for the next write and flush, the earlier of the
+// write timeout and the context's deadline. If the context ends while writing, the
+// write fails straight away. The returned function must be called after writing.
+func (t *netTransport) setDeadline(ctx context.Context) (stop func() bool) {
+	var deadline time.Time
+	if t.writeTimeout > 0 {
+		deadline = time.Now().Add(t.writeTimeout)
+	}
+
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	// the deadline is only cleared if it was set, as most writes have none.
+	if !deadline.IsZero() || t.deadline {
+		t.conn.SetWriteDeadline(deadline)
+	}
+	t.deadline = !deadline.IsZero()
+
+	if ctx.Done() == nil {
+		return noStop
+	}
+
+	t.deadline = true
+	return context.AfterFunc(ctx, func() {
+		t.conn.SetWriteDeadline(time.Unix(1, 0))
+	})
+}
+
+func noStop() bool { return false }
+
+// Write implements the Transport interface.
+func (t *netTransport) Write(message []byte) (int, error) {
+	n, err := t.w.Write(message)
+	if err != nil {
+		return 0, t.writeError(err)
+	}
+
+	// stream connections need each message on its own line.
+	if n != 0 && t.stream() {
+		err = t.w.WriteByte('\n')
+		if err != nil {
+			return n, t.writeError(err)
+		}
+	}
+
+	return n, nil
+}
+
+// Flush implements the Transport interface.
+func (t *netTransport) Flush() error {
+	err := t.w.Flush()
+	if err != nil {
+		return t.writeError(err)
+	}
+
+	return nil
+}
+
+// Close implements the Transport interface.
+func (t *netTransport) Close() error {
+	return t.conn.Close()
+}
+
+// stream returns true if the connection is a stream of bytes,
+// rather than a datagram per message.
+func (t *netTransport) stream() bool {
+	switch t.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+
+	return false
+}
+
+// writeError returns ErrSocketBufferFull if the write failed because the socket's
+// buffer is full, after discarding the stat so the connection can be used again.
+// A failed write on a stream connection may have sent part of a line, so
+// the error is returned as is and the buffer keeps failing until reconnected,
+// as sending anything else on the connection would corrupt the next line.
+func (t *netTransport) writeError(err error) error {
+	if t.stream() {
+		return err
+	}
+
+	var netErr net.Error
+	full := errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK)
+	if t.network == "unixgram" && errors.As(err, &netErr) && netErr.Timeout() {
+		full = true
+	}
+
+	if !full {
+		return err
+	}
+
+	t.w.Reset(t.conn)
+	return ErrSocketBufferFull
+}
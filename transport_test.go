@@ -0,0 +1,248 @@
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// failingTransport fails writes with err until ok.
+type failingTransport struct {
+	bufferTransport
+	err    error
+	closed bool
+}
+
+func (t *failingTransport) Write(message []byte) (int, error) {
+	if t.err != nil {
+		return 0, t.err
+	}
+
+	return t.bufferTransport.Write(message)
+}
+
+func (t *failingTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestWithTransport(t *testing.T) {
+	var transports []*failingTransport
+	open := func() (Transport, error) {
+		transport := &failingTransport{bufferTransport: bufferTransport{&bytes.Buffer{}}}
+		transports = append(transports, transport)
+		return transport, nil
+	}
+
+	c, err := NewWithOptions("memory", WithPrefix("prefix"), WithTransport(open), WithReconnectDelay(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if a := c.ActiveAddress(); a != "memory" {
+		t.Fatalf("expected memory, got %s", a)
+	}
+
+	c.Count("count")
+
+	expected := "prefix.count:1|c"
+	if b := transports[0].String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// a full buffer drops the stat but keeps the transport
+	transports[0].err = ErrSocketBufferFull
+	err = c.Count("count")
+	if err != ErrSocketBufferFull {
+		t.Fatalf("expected ErrSocketBufferFull, got %v", err)
+	}
+
+	if len(transports) != 1 {
+		t.Fatalf("should not have reopened the transport, got %d", len(transports))
+	}
+
+	// other errors reopen it
+	transports[0].err = errors.New("broken")
+	err = c.Count("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transports) != 2 || !transports[0].closed {
+		t.Fatal("should have closed the failed transport and opened another")
+	}
+
+	if b := transports[1].String(); b != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	// opening fails
+	_, err = NewWithOptions("memory", WithTransport(func() (Transport, error) {
+		return nil, errors.New("can not open")
+	}))
+	if err == nil {
+		t.Fatal("should have returned the open error")
+	}
+}
+
+func TestWithDialer(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var mutex sync.Mutex
+	var controlled string
+	dialer := &net.Dialer{
+		Timeout:   time.Second,
+		LocalAddr: &net.UDPAddr{IP: net.ParseIP("127.0.0.1")},
+		Control: func(network, address string, c syscall.RawConn) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			controlled = address
+			return nil
+		},
+	}
+
+	c, err := NewWithOptions(l.LocalAddr().String(), WithDialer(dialer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	mutex.Lock()
+	if controlled != l.LocalAddr().String() {
+		t.Errorf("should have called Control for %s, got %s", l.LocalAddr(), controlled)
+	}
+	mutex.Unlock()
+
+	c.Count("count")
+
+	b := make([]byte, 1024)
+	l.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := l.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p, e := string(b[:n]), "count:1|c"; p != e {
+		t.Fatalf("expected %s, got %s", e, p)
+	}
+
+	if ip := addr.(*net.UDPAddr).IP; !ip.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("should have sent from the local address, got %v", ip)
+	}
+}
+
+func TestWithDialFunc(t *testing.T) {
+	var network, address string
+	dial := func(ctx context.Context, n, a string) (net.Conn, error) {
+		network, address = n, a
+		return nil, errors.New("no route")
+	}
+
+	_, err := NewWithOptions("tcp://statsd:8125", WithDialFunc(dial))
+	if err == nil || err.Error() != "no route" {
+		t.Fatalf("expected the dial error, got %v", err)
+	}
+
+	if network != "tcp" || address != "statsd:8125" {
+		t.Errorf("incorrect network and address, got %s %s", network, address)
+	}
+}
+
+func TestWithDialTimeout(t *testing.T) {
+	blackhole := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	_, err := NewWithOptions("tcp://statsd:8125", WithDialFunc(blackhole), WithDialTimeout(20*time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("should have given up dialing, took %v", d)
+	}
+}
+
+func TestReconnectDoesNotBlockWrites(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conns := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conns <- conn
+		}
+	}()
+
+	// the first dial connects, the next one hangs
+	var calls int32
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}
+
+		close(dialing)
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil, errors.New("unreachable")
+	}
+
+	c, err := NewWithOptions("tcp://"+l.Addr().String(), WithDialFunc(dial), WithReconnectDelay(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	defer close(release)
+
+	conn := <-conns
+	conn.Close()
+
+	// writes fail once the server is gone, and one of them reconnects
+	go func() {
+		for {
+			c.Count("count")
+
+			select {
+			case <-dialing:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	select {
+	case <-dialing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("should have reconnected")
+	}
+
+	start := time.Now()
+	c.Count("count")
+	c.ActiveAddress()
+
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("should not wait for the reconnect, took %v", d)
+	}
+}
@@ -0,0 +1,125 @@
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewFromURL opens a new connection configured by the URL, such as
+//
+//	udp://statsd:8125/gopher_service?rate=0.5&reconnect=2s&tags=env:prod,region:eu
+//	unixgram:///var/run/statsd.sock?prefix=gopher_service
+//
+// The scheme is the network, any that New supports. For host:port networks the
+// path is the prefix, with slashes replaced by dots, while for unix sockets the
+// path is the socket and the prefix is set with the prefix parameter.
+// The parameters are:
+//
+//	prefix     prepended to every stat
+//	rate       the client's DefaultRate, between 0 and 1
+//	reconnect  the ReconnectDelay, such as 500ms or 2s
+//	tags       comma separated DogStatsD tags, may be repeated
+//	lazy       true to connect in the background, see WithLazyConnect
+//
+// Options are applied after the URL so they can override it.
+func NewFromURL(rawURL string, options ...Option) (*RemoteClient, error) {
+	address, urlOptions, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithOptions(address, append(urlOptions, options...)...)
+}
+
+// parseURL returns the address for NewWithOptions and the options set by the URL.
+func parseURL(rawURL string) (string, []Option, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid statsd url: %v", err)
+	}
+
+	if u.Scheme == "" {
+		return "", nil, fmt.Errorf("statsd url %s has no network, such as udp://", rawURL)
+	}
+
+	if u.User != nil || u.Fragment != "" {
+		return "", nil, fmt.Errorf("statsd url %s can not have a user or fragment", rawURL)
+	}
+
+	if _, _, err := splitNetwork(u.Scheme + "://"); err != nil {
+		return "", nil, fmt.Errorf("statsd url %s: %v", rawURL, err)
+	}
+
+	var address, prefix string
+	if strings.HasPrefix(u.Scheme, "unix") {
+		if u.Host != "" || u.Path == "" {
+			return "", nil, fmt.Errorf("statsd url %s must have an absolute socket path, such as %s:///var/run/statsd.sock", rawURL, u.Scheme)
+		}
+		address = u.Path
+	} else {
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil || host == "" || port == "" {
+			return "", nil, fmt.Errorf("statsd url %s must have a host and port", rawURL)
+		}
+		address = u.Host
+		prefix = strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", ".")
+	}
+
+	address = u.Scheme + "://" + address
+
+	var options []Option
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+
+		switch key {
+		case "prefix":
+			if prefix != "" {
+				return "", nil, fmt.Errorf("statsd url %s sets the prefix in both the path and parameters", rawURL)
+			}
+			prefix = value
+		case "rate":
+			rate, err := strconv.ParseFloat(value, 32)
+			if err != nil || rate <= 0 || rate > 1 {
+				return "", nil, fmt.Errorf("statsd url %s has invalid rate %s, must be more than 0 and at most 1", rawURL, value)
+			}
+			options = append(options, func(client *RemoteClient) { client.DefaultRate = float32(rate) })
+		case "reconnect":
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay <= 0 {
+				return "", nil, fmt.Errorf("statsd url %s has invalid reconnect %s, must be a positive duration such as 2s", rawURL, value)
+			}
+			options = append(options, WithReconnectDelay(delay))
+		case "tags":
+			var tags []string
+			for _, v := range values {
+				for _, tag := range strings.Split(v, ",") {
+					if tag == "" {
+						return "", nil, fmt.Errorf("statsd url %s has an empty tag", rawURL)
+					}
+					tags = append(tags, tag)
+				}
+			}
+			options = append(options, func(client *RemoteClient) { client.Tags = append(client.Tags, tags...) })
+		case "lazy":
+			lazy, err := strconv.ParseBool(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("statsd url %s has invalid lazy %s, must be true or false", rawURL, value)
+			}
+			if lazy {
+				options = append(options, WithLazyConnect())
+			}
+		default:
+			return "", nil, fmt.Errorf("statsd url %s has unknown parameter %s", rawURL, key)
+		}
+	}
+
+	if prefix != "" {
+		options = append(options, WithPrefix(prefix))
+	}
+
+	return address, options, nil
+}